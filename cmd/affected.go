@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/spf13/cobra"
+
+	"github.com/piotrplenik/terragrunt-atlantis-config/pkg/generator"
+)
+
+var changedFiles []string
+var affectedFormat string
+var includeDependents bool
+
+// Reads one changed file path per line, ignoring blank lines
+func readChangedFiles(in io.Reader) ([]string, error) {
+	files := []string{}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, scanner.Err()
+}
+
+// Makes changed file paths relative to the git root, as the `when_modified` patterns are matched from there
+func relativeToGitRoot(files []string) ([]string, error) {
+	absoluteGitRoot, err := filepath.Abs(generateOptions.GitRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	relativeFiles := []string{}
+	for _, file := range files {
+		if filepath.IsAbs(file) {
+			file, err = filepath.Rel(absoluteGitRoot, file)
+			if err != nil {
+				return nil, err
+			}
+		}
+		relativeFiles = append(relativeFiles, filepath.ToSlash(file))
+	}
+
+	return relativeFiles, nil
+}
+
+// Prints the affected projects in the format given by `--format`
+func printAffectedProjects(out io.Writer, projects []generator.AffectedProject, affectedFormat string) error {
+	switch affectedFormat {
+	case "plain":
+		for _, project := range projects {
+			fmt.Fprintln(out, project.Dir)
+		}
+		return nil
+	case "json":
+		bytes, err := json.MarshalIndent(projects, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(bytes))
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of: plain, json", affectedFormat)
+	}
+}
+
+// affectedCmd represents the affected command
+var affectedCmd = &cobra.Command{
+	Use:   "affected",
+	Short: "Lists the projects impacted by a set of changed files",
+	Long:  `Matches changed files, read from --files or one per line from stdin, against the when_modified patterns of every generated project`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := options.NewTerragruntOptions()
+
+		l := log.New(
+			log.WithOutput(opts.ErrWriter),
+			log.WithLevel(options.DefaultLogLevel),
+			log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())),
+		)
+
+		files := changedFiles
+		if len(files) == 0 {
+			stdinFiles, err := readChangedFiles(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			files = stdinFiles
+		}
+
+		files, err := relativeToGitRoot(files)
+		if err != nil {
+			return err
+		}
+
+		config, err := generateConfig(cmd.Context(), l)
+		if err != nil {
+			return err
+		}
+
+		projects, err := generator.AffectedProjects(config, files, includeDependents)
+		if err != nil {
+			return err
+		}
+
+		return printAffectedProjects(cmd.OutOrStdout(), projects, affectedFormat)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(affectedCmd)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	addGenerateFlags(affectedCmd.PersistentFlags(), pwd)
+	affectedCmd.PersistentFlags().StringSliceVar(&changedFiles, "files", []string{}, "Comma-separated paths of the changed files, relative to the root. Default is to read one path per line from stdin")
+	affectedCmd.PersistentFlags().StringVar(&affectedFormat, "format", "plain", "Output format. One of: plain, json")
+	affectedCmd.PersistentFlags().BoolVar(&includeDependents, "include-dependents", false, "Also lists the projects depending on an affected project through depends_on, all the way down. Requires --depends-on")
+}
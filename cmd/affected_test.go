@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs the affected command with the given stdin, returning its output
+func runAffected(t *testing.T, stdin string, args []string) string {
+	err := resetForRun()
+	if err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+	defer func() {
+		changedFiles = []string{}
+		affectedFormat = "plain"
+		includeDependents = false
+	}()
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetIn(strings.NewReader(stdin))
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetIn(nil)
+
+	rootCmd.SetArgs(append([]string{"affected"}, args...))
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String()
+}
+
+func TestAffectedFromStdin(t *testing.T) {
+	out := runAffected(t, "depender/terragrunt.hcl\n\nunrelated/file.txt\n", []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+	})
+
+	assert.Equal(t, "depender\ndepender_on_depender\n", out)
+}
+
+func TestAffectedWithoutCascadingDependencies(t *testing.T) {
+	out := runAffected(t, "", []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--files",
+		"dependency/main.tf",
+	})
+
+	assert.Equal(t, "dependency\n", out)
+}
+
+func TestAffectedIncludingDependents(t *testing.T) {
+	out := runAffected(t, "", []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--depends-on",
+		"--create-project-name",
+		"--include-dependents",
+		"--format",
+		"json",
+		"--files",
+		"dependency/main.tf",
+	})
+
+	assert.JSONEq(t, `[
+  {"dir": "dependency", "name": "dependency", "reason": "modified", "cause": "dependency/main.tf"},
+  {"dir": "depender", "name": "depender", "reason": "dependent", "cause": "dependency"},
+  {"dir": "depender_on_depender", "name": "depender_on_depender", "reason": "dependent", "cause": "depender"},
+  {"dir": "depender_on_depender/nested", "name": "depender_on_depender_nested", "reason": "dependent", "cause": "dependency"}
+]`, out)
+}
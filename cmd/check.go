@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/spf13/cobra"
+
+	"github.com/piotrplenik/terragrunt-atlantis-config/pkg/generator"
+)
+
+// The differences found between a committed config and a freshly generated one
+type configDiff struct {
+	// Top level settings (automerge, parallel_plan, ...) whose values differ
+	Settings []string
+
+	// Keys of projects only present in the generated config
+	Added []string
+
+	// Keys of projects only present in the committed config
+	Removed []string
+
+	// Keys of projects present in both configs, mapped to the fields that differ
+	Changed map[string][]string
+}
+
+func (d configDiff) isEmpty() bool {
+	return len(d.Settings) == 0 && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Identifies a project in a config. Dir alone is not unique once workspaces or names are in use
+func projectKey(project generator.AtlantisProject) string {
+	key := project.Dir
+	if project.Workspace != "" {
+		key += " (workspace: " + project.Workspace + ")"
+	}
+	if project.Name != "" {
+		key += " (name: " + project.Name + ")"
+	}
+	return key
+}
+
+// Converts a value to its generic JSON representation, so two values can be compared key by key
+func toGenericMap(value interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	generic := map[string]interface{}{}
+	err = json.Unmarshal(bytes, &generic)
+	return generic, err
+}
+
+// Sorts the lists Atlantis treats as sets, so their ordering is not reported as drift
+func normalizeProject(project generator.AtlantisProject) generator.AtlantisProject {
+	project.Autoplan.WhenModified = append([]string{}, project.Autoplan.WhenModified...)
+	sort.Strings(project.Autoplan.WhenModified)
+
+	if project.DependsOn != nil {
+		project.DependsOn = append([]string{}, project.DependsOn...)
+		sort.Strings(project.DependsOn)
+	}
+
+	return project
+}
+
+// Lists the keys whose values differ between two generic maps
+func changedKeys(committed map[string]interface{}, generated map[string]interface{}) []string {
+	keys := map[string]bool{}
+	for key := range committed {
+		keys[key] = true
+	}
+	for key := range generated {
+		keys[key] = true
+	}
+
+	changed := []string{}
+	for key := range keys {
+		if !reflect.DeepEqual(committed[key], generated[key]) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+// The repo-level settings of a config
+func withoutProjects(config generator.AtlantisConfig) generator.AtlantisConfig {
+	config.Projects = nil
+	return config
+}
+
+// Compares two configs semantically. Formatting and key order are irrelevant, as both configs
+// have already been unmarshalled into an AtlantisConfig
+func diffConfigs(committed *generator.AtlantisConfig, generated *generator.AtlantisConfig) (configDiff, error) {
+	diff := configDiff{Changed: map[string][]string{}}
+
+	// Every repo-level key is a setting, including the ones kept from the committed config
+	oldSettings, err := toGenericMap(withoutProjects(*committed))
+	if err != nil {
+		return diff, err
+	}
+	newSettings, err := toGenericMap(withoutProjects(*generated))
+	if err != nil {
+		return diff, err
+	}
+	diff.Settings = changedKeys(oldSettings, newSettings)
+
+	oldProjects := map[string]generator.AtlantisProject{}
+	for _, project := range committed.Projects {
+		oldProjects[projectKey(project)] = project
+	}
+
+	newProjects := map[string]generator.AtlantisProject{}
+	for _, project := range generated.Projects {
+		key := projectKey(project)
+		newProjects[key] = project
+
+		oldProject, ok := oldProjects[key]
+		if !ok {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+
+		oldProjectMap, err := toGenericMap(normalizeProject(oldProject))
+		if err != nil {
+			return diff, err
+		}
+		newProjectMap, err := toGenericMap(normalizeProject(project))
+		if err != nil {
+			return diff, err
+		}
+		if fields := changedKeys(oldProjectMap, newProjectMap); len(fields) > 0 {
+			diff.Changed[key] = fields
+		}
+	}
+
+	for _, project := range committed.Projects {
+		key := projectKey(project)
+		if _, ok := newProjects[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+
+	return diff, nil
+}
+
+// Prints a human readable summary of a diff
+func printConfigDiff(out io.Writer, diff configDiff) {
+	if len(diff.Settings) > 0 {
+		fmt.Fprintln(out, "Settings changed:")
+		for _, setting := range diff.Settings {
+			fmt.Fprintln(out, "  ~ "+setting)
+		}
+	}
+
+	if len(diff.Added) > 0 {
+		fmt.Fprintln(out, "Projects added:")
+		for _, key := range diff.Added {
+			fmt.Fprintln(out, "  + "+key)
+		}
+	}
+
+	if len(diff.Removed) > 0 {
+		fmt.Fprintln(out, "Projects removed:")
+		for _, key := range diff.Removed {
+			fmt.Fprintln(out, "  - "+key)
+		}
+	}
+
+	if len(diff.Changed) > 0 {
+		fmt.Fprintln(out, "Projects changed:")
+		keys := []string{}
+		for key := range diff.Changed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintln(out, "  ~ "+key+": "+strings.Join(diff.Changed[key], ", "))
+		}
+	}
+}
+
+// Generates a fresh config and compares it with the one at `outputPath`, returning an error on drift
+func check(cmd *cobra.Command, log log.Logger) error {
+	if generateOptions.OutputPath == "" {
+		return fmt.Errorf("--output is required to know which config to check")
+	}
+
+	oldConfig, err := generator.ReadOldConfig(log, generateOptions.OutputPath)
+	if err != nil {
+		return err
+	}
+	if oldConfig == nil {
+		oldConfig = &generator.AtlantisConfig{}
+	}
+
+	newConfig, err := generateConfig(cmd.Context(), log)
+	if err != nil {
+		return err
+	}
+
+	diff, err := diffConfigs(oldConfig, newConfig)
+	if err != nil {
+		return err
+	}
+
+	if diff.isEmpty() {
+		fmt.Fprintln(cmd.OutOrStdout(), generateOptions.OutputPath+" is up to date")
+		return nil
+	}
+
+	printConfigDiff(cmd.OutOrStdout(), diff)
+	return fmt.Errorf("%s is out of date, run `%s generate` to update it", generateOptions.OutputPath, rootCmd.Use)
+}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks that an existing atlantis config is up to date",
+	Long:  `Generates Atlantis config in memory and compares it with the file given by --output, exiting non-zero on drift`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := options.NewTerragruntOptions()
+
+		l := log.New(
+			log.WithOutput(opts.ErrWriter),
+			log.WithLevel(options.DefaultLogLevel),
+			log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())),
+		)
+		return check(cmd, l)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	addGenerateFlags(checkCmd.PersistentFlags(), pwd)
+}
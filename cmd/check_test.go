@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs the check command against a config file with the given contents
+func runCheck(t *testing.T, contents []byte, args []string) (string, error) {
+	err := resetForRun()
+	if err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	err = os.WriteFile(filename, contents, 0644)
+	if err != nil {
+		t.Fatal("Failed to write config file")
+	}
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	defer rootCmd.SetOut(nil)
+
+	rootCmd.SetArgs(append([]string{
+		"check",
+		"--output",
+		filename,
+	}, args...))
+	err = rootCmd.Execute()
+
+	return out.String(), err
+}
+
+func TestCheckUpToDate(t *testing.T) {
+	// The golden file uses a different key order and indentation than the generator produces
+	contents := []byte(`version: 3
+parallel_plan: true
+parallel_apply: true
+automerge: false
+projects:
+  - dir: .
+    autoplan:
+      when_modified: ["*.tf*", "terragrunt.hcl"]
+      enabled: false
+`)
+
+	out, err := runCheck(t, contents, []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, out, "is up to date")
+}
+
+func TestCheckDetectsDrift(t *testing.T) {
+	contents := []byte(`version: 3
+automerge: true
+parallel_plan: true
+parallel_apply: true
+projects:
+- dir: depender
+  workflow: oldWorkflow
+  autoplan:
+    enabled: false
+    when_modified:
+    - '*.tf*'
+    - terragrunt.hcl
+    - ../dependency/terragrunt.hcl
+- dir: removed
+  autoplan:
+    enabled: false
+    when_modified:
+    - terragrunt.hcl
+`)
+
+	out, err := runCheck(t, contents, []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--preserve-projects=false",
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, `Settings changed:
+  ~ automerge
+Projects added:
+  + dependency
+  + depender_on_depender
+  + depender_on_depender/nested
+Projects removed:
+  - removed
+Projects changed:
+  ~ depender: workflow
+`, out)
+}
+
+func TestCheckDetectsRepoSettingDrift(t *testing.T) {
+	contents := []byte(`version: 3
+parallel_plan: true
+parallel_apply: true
+automerge: false
+projects:
+- dir: .
+  autoplan:
+    enabled: false
+    when_modified: ["*.tf*", "terragrunt.hcl"]
+`)
+
+	out, err := runCheck(t, contents, []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--abort-on-execution-order-fail",
+		"--autodiscover",
+		"enabled",
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, `Settings changed:
+  ~ abort_on_execution_order_fail
+  ~ autodiscover
+`, out)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Name of the config file looked up at the root when `--config` isn't passed
+const defaultConfigFileName = ".terragrunt-atlantis-config.yaml"
+
+// Path of the config file passed with `--config`
+var configFilePath string
+
+// Number of single character edits needed to turn `a` into `b`
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+// Builds the error for a key of the config file that isn't a flag, suggesting the flag that was likely meant
+func unknownConfigKeyError(flags *pflag.FlagSet, path string, key string) error {
+	suggestion := ""
+	bestDistance := 3
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "config" {
+			return
+		}
+		distance := editDistance(strings.ReplaceAll(key, "_", "-"), flag.Name)
+		if distance < bestDistance {
+			bestDistance = distance
+			suggestion = flag.Name
+		}
+	})
+
+	if suggestion != "" {
+		return fmt.Errorf("%s: unknown key %q, did you mean %q?", path, key, suggestion)
+	}
+	return fmt.Errorf("%s: unknown key %q. Keys are the names of the flags of this command without the leading dashes, see --help", path, key)
+}
+
+// Sets a flag to a value of the config file, without marking it as passed on the command line
+func setFlagFromConfig(flag *pflag.Flag, value interface{}) error {
+	switch typedValue := value.(type) {
+	case []interface{}:
+		sliceValue, ok := flag.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("%q takes a single value, not a list", flag.Name)
+		}
+
+		items := []string{}
+		for _, item := range typedValue {
+			items = append(items, fmt.Sprint(item))
+		}
+		return sliceValue.Replace(items)
+	case map[string]interface{}:
+		return fmt.Errorf("%q takes a single value, not an object", flag.Name)
+	case nil:
+		return fmt.Errorf("%q has no value", flag.Name)
+	default:
+		err := flag.Value.Set(fmt.Sprint(typedValue))
+		if err != nil {
+			return fmt.Errorf("invalid value for %q: %w", flag.Name, err)
+		}
+		return nil
+	}
+}
+
+// Fills in the flags of `cmd` that weren't passed on the command line from the config file. The file is the one
+// passed with `--config`, or else the default one at `--root`, which may not exist
+func loadConfigFile(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Lookup("config") == nil {
+		return nil
+	}
+
+	path := configFilePath
+	if path == "" {
+		path = filepath.Join(generateOptions.GitRoot, defaultConfigFileName)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(bytes, &values)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		flag := flags.Lookup(key)
+		if flag == nil || key == "config" {
+			return unknownConfigKeyError(flags, path, key)
+		}
+
+		// Flags passed on the command line take precedence
+		if flag.Changed {
+			continue
+		}
+
+		err := setFlagFromConfig(flag, values[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
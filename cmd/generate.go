@@ -1,818 +1,47 @@
 package cmd
 
 import (
-	"regexp"
-	"sort"
-
 	"github.com/gruntwork-io/terragrunt/options"
 	"github.com/gruntwork-io/terragrunt/pkg/log"
 	"github.com/gruntwork-io/terragrunt/pkg/log/format"
-	"github.com/hashicorp/go-getter"
 
-	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/semaphore"
-	"golang.org/x/sync/singleflight"
+	"github.com/piotrplenik/terragrunt-atlantis-config/pkg/generator"
 
 	"context"
 	"os"
-	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
-	"sync"
 )
 
-// Terragrunt imports can be relative or absolute
-// This makes relative paths absolute
-func makePathAbsolute(path string, parentPath string) string {
-	if strings.HasPrefix(path, filepath.ToSlash(gitRoot)) {
-		return path
-	}
-
-	parentDir := filepath.Dir(parentPath)
-	return filepath.Join(parentDir, path)
-}
-
-var requestGroup singleflight.Group
-
-// Set up a cache for the getDependencies function
-type getDependenciesOutput struct {
-	dependencies []string
-	err          error
-}
-
-type GetDependenciesCache struct {
-	mtx  sync.RWMutex
-	data map[string]getDependenciesOutput
-}
-
-func newGetDependenciesCache() *GetDependenciesCache {
-	return &GetDependenciesCache{data: map[string]getDependenciesOutput{}}
-}
-
-func (m *GetDependenciesCache) set(k string, v getDependenciesOutput) {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
-	m.data[k] = v
-}
-
-func (m *GetDependenciesCache) get(k string) (getDependenciesOutput, bool) {
-	m.mtx.RLock()
-	defer m.mtx.RUnlock()
-	v, ok := m.data[k]
-	return v, ok
-}
-
-var getDependenciesCache = newGetDependenciesCache()
-
-func uniqueStrings(str []string) []string {
-	keys := make(map[string]bool)
-	list := []string{}
-	for _, entry := range str {
-		if _, value := keys[entry]; !value {
-			keys[entry] = true
-			list = append(list, entry)
-		}
-	}
-	return list
-}
-
-func lookupProjectHcl(m map[string][]string, value string) (key string) {
-	for k, values := range m {
-		for _, val := range values {
-			if val == value {
-				key = k
-				return
-			}
-		}
-	}
-	return key
-}
-
-// sliceUnion takes two slices of strings and produces a union of them, containing only unique values
-func sliceUnion(a, b []string) []string {
-	m := make(map[string]bool)
-
-	for _, item := range a {
-		m[item] = true
-	}
-
-	for _, item := range b {
-		if _, ok := m[item]; !ok {
-			a = append(a, item)
-		}
-	}
-	return a
-}
-
-// Parses the terragrunt config at `path` to find all modules it depends on
-func getDependencies(ctx *TerragruntParsingContext, log log.Logger, path string) ([]string, error) {
-	res, err, _ := requestGroup.Do(path, func() (interface{}, error) {
-		// Check if this path has already been computed
-		cachedResult, ok := getDependenciesCache.get(path)
-		if ok {
-			return cachedResult.dependencies, cachedResult.err
-		}
-
-		// parse the module path to find what it includes, as well as its potential to be a parent
-		// return nils to indicate we should skip this project
-		isParent, includes, err := parseModule(ctx, log, path)
-		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
-		}
-		if isParent && ignoreParentTerragrunt {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, nil})
-			return nil, nil
-		}
-
-		dependencies := []string{}
-		if len(includes) > 0 {
-			for _, includeDep := range includes {
-				getDependenciesCache.set(includeDep.Path, getDependenciesOutput{nil, err})
-				dependencies = append(dependencies, includeDep.Path)
-			}
-		}
-
-		// Parse the HCL file
-		parseCtx := NewParsingContextWithDecodeList(ctx, log)
-		terragruntConfig, err := parseCtx.PartialParseConfigFile(log, path)
-		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
-		}
-
-		// Parse out locals
-		locals, err := parseLocals(ctx, log, path, nil)
-		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
-		}
-
-		// Get deps from locals
-		if locals.ExtraAtlantisDependencies != nil {
-			dependencies = sliceUnion(dependencies, locals.ExtraAtlantisDependencies)
-		}
-
-		// Get deps from `dependencies` and `dependency` blocks
-		if terragruntConfig.Dependencies != nil && !ignoreDependencyBlocks {
-			for _, parsedPaths := range terragruntConfig.Dependencies.Paths {
-				dependencies = append(dependencies, filepath.Join(parsedPaths, "terragrunt.hcl"))
-			}
-		}
-
-		// Get deps from the `Source` field of the `Terraform` block
-		if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.Source != nil {
-			source := terragruntConfig.Terraform.Source
-
-			// Use `go-getter` to normalize the source paths
-			parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
-			if err != nil {
-				return nil, err
-			}
-
-			// Check if the path begins with a drive letter, denoting Windows
-			isWindowsPath, err := regexp.MatchString(`^[A-Za-z]:`, parsedSource)
-			if err != nil {
-				return nil, err
-			}
-
-			// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
-			if strings.HasPrefix(parsedSource, "file://") || isWindowsPath {
-				// Remove the prefix so we have a valid filesystem path
-				parsedSource = strings.TrimPrefix(parsedSource, "file://")
-
-				dependencies = append(dependencies, filepath.Join(parsedSource, "*.tf*"))
-
-				ls, err := parseTerraformLocalModuleSource(parsedSource)
-				if err != nil {
-					return nil, err
-				}
-				sort.Strings(ls)
-
-				dependencies = append(dependencies, ls...)
-			}
-		}
-
-		// Get deps from `extra_arguments` fields of the `Terraform` block
-		if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.ExtraArgs != nil {
-			extraArgs := terragruntConfig.Terraform.ExtraArgs
-			for _, arg := range extraArgs {
-				if arg.RequiredVarFiles != nil {
-					dependencies = append(dependencies, *arg.RequiredVarFiles...)
-				}
-				if arg.OptionalVarFiles != nil {
-					dependencies = append(dependencies, *arg.OptionalVarFiles...)
-				}
-				if arg.Arguments != nil {
-					for _, cliFlag := range *arg.Arguments {
-						if strings.HasPrefix(cliFlag, "-var-file=") {
-							dependencies = append(dependencies, strings.TrimPrefix(cliFlag, "-var-file="))
-						}
-					}
-				}
-			}
-		}
-
-		// Filter out and dependencies that are the empty string
-		nonEmptyDeps := []string{}
-		for _, dep := range dependencies {
-			if dep != "" {
-				childDepAbsPath := dep
-				if !filepath.IsAbs(childDepAbsPath) {
-					childDepAbsPath = makePathAbsolute(dep, path)
-				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-				nonEmptyDeps = append(nonEmptyDeps, childDepAbsPath)
-			}
-		}
-
-		// Recurse to find dependencies of all dependencies
-		cascadedDeps := []string{}
-		for _, dep := range nonEmptyDeps {
-			cascadedDeps = append(cascadedDeps, dep)
-
-			// The "cascading" feature is protected by a flag
-			if !cascadeDependencies {
-				continue
-			}
-
-			depPath := dep
-			terrContext := ctx.WithDependencyPath(depPath, log)
-			childDeps, err := getDependencies(terrContext, log, depPath)
-			if err != nil {
-				continue
-			}
-
-			for _, childDep := range childDeps {
-				// If `childDep` is a relative path, it will be relative to `childDep`, as it is from the nested
-				// `getDependencies` call on the top level module's dependencies. So here we update any relative
-				// path to be from the top level module instead.
-				childDepAbsPath := childDep
-				if !filepath.IsAbs(childDep) {
-					childDepAbsPath, err = filepath.Abs(filepath.Join(depPath, "..", childDep))
-					if err != nil {
-						getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-						return nil, err
-					}
-				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-
-				// Ensure we are not adding a duplicate dependency
-				alreadyExists := false
-				for _, dep := range cascadedDeps {
-					if dep == childDepAbsPath {
-						alreadyExists = true
-						break
-					}
-				}
-				if !alreadyExists {
-					cascadedDeps = append(cascadedDeps, childDepAbsPath)
-				}
-			}
-		}
-
-		if filepath.Base(path) == "terragrunt.hcl" || filepath.Base(path) == "terragrunt.stack.hcl" {
-			dir := filepath.Dir(path)
-
-			ls, err := parseTerraformLocalModuleSource(dir)
-			if err != nil {
-				return nil, err
-			}
-			sort.Strings(ls)
-
-			cascadedDeps = append(cascadedDeps, ls...)
-		}
-
-		getDependenciesCache.set(path, getDependenciesOutput{cascadedDeps, err})
-		return cascadedDeps, nil
-	})
-
-	if res != nil {
-		return res.([]string), err
-	} else {
-		return nil, err
-	}
-}
-
-// Creates an AtlantisProject for a directory
-func createProject(ctx context.Context, log log.Logger, sourcePath string) (*AtlantisProject, error) {
-	parsingContext, err := NewParsingContextWithConfigPath(ctx, log, sourcePath)
-	if err != nil {
-		return nil, err
-	}
-
-	dependencies, err := getDependencies(parsingContext, log, sourcePath)
-	if err != nil {
-		return nil, err
-	}
-
-	// dependencies being nil is a sign from `getDependencies` that this project should be skipped
-	if dependencies == nil && !strings.HasSuffix(sourcePath, "terragrunt.stack.hcl") {
-		return nil, nil
-	}
-
-	absoluteSourceDir := filepath.Dir(sourcePath) + string(filepath.Separator)
-	locals, err := parseLocals(parsingContext, log, sourcePath, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// If `atlantis_skip` is true on the module, then do not produce a project for it
-	if locals.Skip != nil && *locals.Skip {
-		return nil, nil
-	}
-
-	// All dependencies depend on their own .hcl file, and any tf files in their directory
-	relativeDependencies := []string{}
-
-	if strings.HasSuffix(sourcePath, "terragrunt.hcl") {
-		relativeDependencies = append(relativeDependencies, "terragrunt.hcl")
-	} else if strings.HasSuffix(sourcePath, "terragrunt.stack.hcl") {
-		relativeDependencies = append(relativeDependencies, "terragrunt.stack.hcl")
-	} else if strings.HasSuffix(sourcePath, "terragrunt.hcl.json") {
-		relativeDependencies = append(relativeDependencies, "terragrunt.hcl.json")
-	} else {
-		panic("Unexpected terragrunt file name: " + sourcePath)
-	}
-
-	relativeDependencies = append(relativeDependencies, "*.tf*")
-
-	// Add other dependencies based on their relative paths. We always want to output with Unix path separators
-	for _, dependencyPath := range dependencies {
-		absolutePath := dependencyPath
-		if !filepath.IsAbs(absolutePath) {
-			absolutePath = makePathAbsolute(dependencyPath, sourcePath)
-		}
-		relativePath, err := filepath.Rel(absoluteSourceDir, absolutePath)
-		if err != nil {
-			return nil, err
-		}
-
-		relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
-	}
-
-	// Clean up the relative path to the format Atlantis expects
-	relativeSourceDir := strings.TrimPrefix(absoluteSourceDir, gitRoot)
-	relativeSourceDir = strings.TrimSuffix(relativeSourceDir, string(filepath.Separator))
-	if relativeSourceDir == "" {
-		relativeSourceDir = "."
-	}
-
-	workflow := defaultWorkflow
-	if locals.AtlantisWorkflow != "" {
-		workflow = locals.AtlantisWorkflow
-	}
-
-	applyRequirements := &defaultApplyRequirements
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
-	resolvedAutoPlan := autoPlan
-	if locals.AutoPlan != nil {
-		resolvedAutoPlan = *locals.AutoPlan
-	}
-
-	terraformVersion := defaultTerraformVersion
-	if locals.TerraformVersion != "" {
-		terraformVersion = locals.TerraformVersion
-	}
-
-	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(relativeSourceDir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
-		Autoplan: AutoplanConfig{
-			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(relativeDependencies),
-		},
-	}
-
-	// Terraform Cloud limits the workspace names to be less than 90 characters
-	// with letters, numbers, -, and _
-	// https://www.terraform.io/docs/cloud/workspaces/naming.html
-	// It is not clear from documentation whether the normal workspaces have those limitations
-	// However a workspace 97 chars long has been working perfectly.
-	// We are going to use the same name for both workspace & project name as it is unique.
-	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
-	projectName := regex.ReplaceAllString(project.Dir, "_")
-
-	if createProjectName {
-		project.Name = projectName
-	}
-
-	if createWorkspace {
-		project.Workspace = projectName
-	}
-
-	return project, nil
-}
-
-func createHclProject(ctx context.Context, log log.Logger, sourcePaths []string, workingDir string, projectHcl string) (*AtlantisProject, error) {
-	var projectHclDependencies []string
-	var childDependencies []string
-	workflow := defaultWorkflow
-	applyRequirements := &defaultApplyRequirements
-	resolvedAutoPlan := autoPlan
-	terraformVersion := defaultTerraformVersion
-
-	projectHclFile := filepath.Join(workingDir, projectHcl)
-	parsingContext, err := NewParsingContextWithConfigPath(ctx, log, workingDir)
-	if err != nil {
-		return nil, err
-	}
-
-	locals, err := parseLocals(parsingContext, log, projectHclFile, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// If `atlantis_skip` is true on the module, then do not produce a project for it
-	if locals.Skip != nil && *locals.Skip {
-		return nil, nil
-	}
-
-	// if project markers are enabled, check if locals are set
-	markedProject := false
-	if locals.markedProject != nil {
-		markedProject = *locals.markedProject
-	}
-	if useProjectMarkers && !markedProject {
-		return nil, nil
-	}
-
-	if locals.ExtraAtlantisDependencies != nil {
-		for _, dep := range locals.ExtraAtlantisDependencies {
-			relDep, err := filepath.Rel(workingDir, dep)
-			if err != nil {
-				return nil, err
-			}
-			projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relDep))
-		}
-	}
-
-	if locals.AtlantisWorkflow != "" {
-		workflow = locals.AtlantisWorkflow
-	}
-
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
-	if locals.AutoPlan != nil {
-		resolvedAutoPlan = *locals.AutoPlan
-	}
-
-	if locals.TerraformVersion != "" {
-		terraformVersion = locals.TerraformVersion
-	}
-
-	// build dependencies for terragrunt childs in directories below project hcl file
-	for _, sourcePath := range sourcePaths {
-		parsingContext, err := NewParsingContextWithConfigPath(ctx, log, sourcePath)
-		if err != nil {
-			return nil, err
-		}
-		dependencies, err := getDependencies(parsingContext, log, sourcePath)
-		if err != nil {
-			return nil, err
-		}
-		// dependencies being nil is a sign from `getDependencies` that this project should be skipped
-		if dependencies == nil {
-			return nil, nil
-		}
-
-		// All dependencies depend on their own .hcl file, and any tf files in their directory
-		relativeDependencies := []string{
-			"terragrunt.hcl",
-			"*.tf*",
-			"**/*.hcl",
-			"**/*.tf*",
-		}
-
-		// Add other dependencies based on their relative paths. We always want to output with Unix path separators
-		for _, dependencyPath := range dependencies {
-			absolutePath := dependencyPath
-			if !filepath.IsAbs(absolutePath) {
-				absolutePath = makePathAbsolute(dependencyPath, sourcePath)
-			}
-
-			relativePath, err := filepath.Rel(workingDir, absolutePath)
-			if err != nil {
-				return nil, err
-			}
-
-			if !strings.Contains(absolutePath, filepath.ToSlash(workingDir)) {
-				relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
-			}
-		}
-
-		childDependencies = append(childDependencies, relativeDependencies...)
-	}
-	dir, err := filepath.Rel(gitRoot, workingDir)
-	if err != nil {
-		return nil, err
-	}
-
-	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(dir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
-		Autoplan: AutoplanConfig{
-			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(append(childDependencies, projectHclDependencies...)),
-		},
-	}
+// The options all generating commands are run with. The flags of those commands write directly into it
+var generateOptions = generator.DefaultOptions()
 
-	// Terraform Cloud limits the workspace names to be less than 90 characters
-	// with letters, numbers, -, and _
-	// https://www.terraform.io/docs/cloud/workspaces/naming.html
-	// It is not clear from documentation whether the normal workspaces have those limitations
-	// However a workspace 97 chars long has been working perfectly.
-	// We are going to use the same name for both workspace & project name as it is unique.
-	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
-	projectName := regex.ReplaceAllString(project.Dir, "_")
-
-	if createProjectName {
-		project.Name = projectName
-	}
-
-	if createWorkspace {
-		project.Workspace = projectName
-	}
+// Runs the generator with the options set by the flags
+func generateConfig(ctx context.Context, log log.Logger) (*generator.AtlantisConfig, error) {
+	opts := generateOptions
+	opts.Logger = log
 
-	return project, nil
-}
-
-// Finds the absolute paths of all arbitrary project hcl files
-func getAllTerragruntProjectHclFiles(log log.Logger) map[string][]string {
-	projectHclFiles := projectHclFiles
-	orderedHclFilePaths := map[string][]string{}
-	uniqueHclFileAbsPaths := map[string][]string{}
-	for _, projectHclFile := range projectHclFiles {
-		err := filepath.Walk(gitRoot, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if !info.IsDir() && info.Name() == projectHclFile {
-				orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
-			}
-
-			return nil
-		})
-
-		if err != nil {
-			log.Error(err)
-			os.Exit(1)
-		}
-
-		for _, uniquePath := range orderedHclFilePaths[projectHclFile] {
-			uniqueAbsPath, err := filepath.Abs(uniquePath)
-			if err != nil {
-				return nil
-			}
-			uniqueHclFileAbsPaths[projectHclFile] = append(uniqueHclFileAbsPaths[projectHclFile], uniqueAbsPath)
-		}
-	}
-	return uniqueHclFileAbsPaths
+	return generator.Generate(ctx, opts)
 }
 
 func main(ctx context.Context, log log.Logger) error {
-	// Ensure the gitRoot has a trailing slash and is an absolute path
-	absoluteGitRoot, err := filepath.Abs(gitRoot)
+	config, err := generateConfig(ctx, log)
 	if err != nil {
 		return err
 	}
-	gitRoot = absoluteGitRoot + string(filepath.Separator)
-	workingDirs := []string{gitRoot}
-	projectHclDirMap := map[string][]string{}
-	var projectHclDirs []string
-	if len(projectHclFiles) > 0 {
-		workingDirs = nil
-		// map [project-hcl-file] => directories containing project-hcl-file
-		projectHclDirMap = getAllTerragruntProjectHclFiles(log)
-		for _, projectHclFile := range projectHclFiles {
-			projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
-			workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
-		}
-		// parse terragrunt child modules outside the scope of projectHclDirs
-		if createHclProjectExternalChilds {
-			workingDirs = append(workingDirs, gitRoot)
-		}
-	}
-	// Read in the old config, if it already exists
-	oldConfig, err := readOldConfig(log)
-	if err != nil {
-		return err
-	}
-	config := AtlantisConfig{
-		Version:       3,
-		AutoMerge:     autoMerge,
-		ParallelPlan:  parallel,
-		ParallelApply: parallel,
-	}
-	if oldConfig != nil && preserveWorkflows {
-		config.Workflows = oldConfig.Workflows
-	}
-	if oldConfig != nil && preserveProjects {
-		config.Projects = oldConfig.Projects
-	}
-
-	lock := sync.Mutex{}
-	groupContext := context.Background()
-	errGroup, _ := errgroup.WithContext(groupContext)
-	sem := semaphore.NewWeighted(numExecutors)
 
-	for _, workingDir := range workingDirs {
-		terragruntFiles, err := getAllTerragruntFiles(workingDir)
-		if err != nil {
-			return err
-		}
-
-		if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == gitRoot) {
-			// Concurrently looking all dependencies
-			for _, terragruntPath := range terragruntFiles {
-				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
-
-				// don't create atlantis projects already covered by project hcl file projects
-				skipProject := false
-				if createHclProjectExternalChilds && workingDir == gitRoot && len(projectHclDirs) > 0 {
-					for _, projectHclDir := range projectHclDirs {
-						if strings.HasPrefix(terragruntPath, projectHclDir) {
-							skipProject = true
-							break
-						}
-					}
-				}
-				if skipProject {
-					continue
-				}
-				if err := sem.Acquire(ctx, 1); err != nil {
-					return err
-				}
-
-				errGroup.Go(func() error {
-					defer sem.Release(1)
-					project, err := createProject(ctx, log, terragruntPath)
-					if err != nil {
-						return err
-					}
-					// if project and err are nil then skip this project
-					if err == nil && project == nil {
-						return nil
-					}
-
-					// Lock the list as only one goroutine should be writing to config.Projects at a time
-					lock.Lock()
-					defer lock.Unlock()
-
-					// When preserving existing projects, we should update existing blocks instead of creating a
-					// duplicate, when generating something which already has representation
-					if preserveProjects {
-						updateProject := false
-
-						// TODO: with Go 1.19, we can replace for loop with slices.IndexFunc for increased performance
-						for i := range config.Projects {
-							if config.Projects[i].Dir == project.Dir {
-								updateProject = true
-								log.Info("Updated project for ", terragruntPath)
-								config.Projects[i] = *project
-
-								// projects should be unique, let's exit for loop for performance
-								// once first occurrence is found and replaced
-								break
-							}
-						}
-
-						if !updateProject {
-							log.Info("Created project for ", terragruntPath)
-							config.Projects = append(config.Projects, *project)
-						}
-					} else {
-						log.Info("Created project for ", terragruntPath)
-						config.Projects = append(config.Projects, *project)
-					}
-
-					return nil
-				})
-			}
-
-			if err := errGroup.Wait(); err != nil {
-				return err
-			}
-		}
-		if len(projectHclDirs) > 0 && workingDir != gitRoot {
-			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
-			err := sem.Acquire(ctx, 1)
-			if err != nil {
-				return err
-			}
-
-			errGroup.Go(func() error {
-				defer sem.Release(1)
-				project, err := createHclProject(ctx, log, terragruntFiles, workingDir, projectHcl)
-				if err != nil {
-					return err
-				}
-				// if project and err are nil then skip this project
-				if err == nil && project == nil {
-					return nil
-				}
-				// Lock the list as only one goroutine should be writing to config.Projects at a time
-				lock.Lock()
-				defer lock.Unlock()
-
-				log.Info("Created "+projectHcl+" project for ", workingDir)
-				config.Projects = append(config.Projects, *project)
-
-				return nil
-			})
-
-			if err := errGroup.Wait(); err != nil {
-				return err
-			}
-		}
-	}
-
-	// Sort the projects in config by Dir
-	sort.Slice(config.Projects, func(i, j int) bool { return config.Projects[i].Dir < config.Projects[j].Dir })
-
-	if executionOrderGroups || dependsOn {
-		projectsMap := make(map[string]*AtlantisProject, len(config.Projects))
-		for i := range config.Projects {
-			projectsMap[config.Projects[i].Dir] = &config.Projects[i]
-		}
-
-		// Compute order groups in the cycle to avoid incorrect values in cascade dependencies
-		hasChanges := true
-		for i := 0; hasChanges && i <= len(config.Projects); i++ {
-			hasChanges = false
-			for _, project := range config.Projects {
-				executionOrderGroup := 0
-				dependsOnList := []string{}
-				// choose order group based on dependencies
-				for _, dep := range project.Autoplan.WhenModified {
-					depPath := filepath.ToSlash(filepath.Dir(filepath.Join(project.Dir, dep)))
-					if depPath == project.Dir {
-						// skip dependency on oneself
-						continue
-					}
-
-					depProject, ok := projectsMap[depPath]
-					if !ok {
-						// skip not project dependencies
-						continue
-					}
-					if depProject.ExecutionOrderGroup != nil {
-						if *depProject.ExecutionOrderGroup+1 > executionOrderGroup {
-							executionOrderGroup = *depProject.ExecutionOrderGroup + 1
-						}
-					}
-					dependsOnList = append(dependsOnList, depProject.Name)
-				}
-				if projectsMap[project.Dir].ExecutionOrderGroup == nil || *projectsMap[project.Dir].ExecutionOrderGroup != executionOrderGroup {
-					if executionOrderGroups {
-						projectsMap[project.Dir].ExecutionOrderGroup = &executionOrderGroup
-					}
-					if dependsOn {
-						projectsMap[project.Dir].DependsOn = dependsOnList
-					}
-					// repeat the main cycle when changed some project
-					hasChanges = true
-				}
-			}
-		}
-
-		if hasChanges {
-			// Should be unreachable
-			log.Warn("Computing execution_order_groups failed. Probably cycle exists")
-		}
-
-		// Sort by execution_order_group
-		if executionOrderGroups {
-			sort.Slice(config.Projects, func(i, j int) bool {
-				if *config.Projects[i].ExecutionOrderGroup == *config.Projects[j].ExecutionOrderGroup {
-					return config.Projects[i].Dir < config.Projects[j].Dir
-				}
-				return *config.Projects[i].ExecutionOrderGroup < *config.Projects[j].ExecutionOrderGroup
-			})
-		}
+	// A dry run only shows what pruning would remove
+	if generateOptions.PruneDryRun {
+		log.Info("Dry run, the config wasn't written")
+		return nil
 	}
 
 	// Convert config to YAML string
-	yamlBytes, err := yaml.Marshal(&config)
+	yamlBytes, err := generator.MarshalConfig(config)
 	if err != nil {
 		return err
 	}
@@ -825,6 +54,7 @@ func main(ctx context.Context, log log.Logger) error {
 	}
 
 	// Write output
+	outputPath := generateOptions.OutputPath
 	if len(outputPath) != 0 {
 		err := os.WriteFile(outputPath, []byte(yamlString), 0644)
 		if err != nil {
@@ -839,31 +69,6 @@ func main(ctx context.Context, log log.Logger) error {
 	return nil
 }
 
-var gitRoot string
-var autoPlan bool
-var autoMerge bool
-var ignoreParentTerragrunt bool
-var createParentProject bool
-var ignoreDependencyBlocks bool
-var parallel bool
-var createWorkspace bool
-var createProjectName bool
-var defaultTerraformVersion string
-var defaultWorkflow string
-var filterPaths []string
-var outputPath string
-var preserveWorkflows bool
-var preserveProjects bool
-var cascadeDependencies bool
-var defaultApplyRequirements []string
-var numExecutors int64
-var projectHclFiles []string
-var createHclProjectChilds bool
-var createHclProjectExternalChilds bool
-var useProjectMarkers bool
-var executionOrderGroups bool
-var dependsOn bool
-
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "generate",
@@ -912,30 +117,93 @@ func init() {
 		os.Exit(1)
 	}
 
-	generateCmd.PersistentFlags().BoolVar(&autoPlan, "autoplan", false, "Enable auto plan. Default is disabled")
-	generateCmd.PersistentFlags().BoolVar(&autoMerge, "automerge", false, "Enable auto merge. Default is disabled")
-	generateCmd.PersistentFlags().BoolVar(&ignoreParentTerragrunt, "ignore-parent-terragrunt", true, "Ignore parent terragrunt configs (those which don't reference a terraform module). Default is enabled")
-	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those which don't reference a terraform module). Default is disabled")
-	generateCmd.PersistentFlags().BoolVar(&ignoreDependencyBlocks, "ignore-dependency-blocks", false, "When true, dependencies found in `dependency` blocks will be ignored")
-	generateCmd.PersistentFlags().BoolVar(&parallel, "parallel", true, "Enables plans and applys to happen in parallel. Default is enabled")
-	generateCmd.PersistentFlags().BoolVar(&createWorkspace, "create-workspace", false, "Use different workspace for each project. Default is use default workspace")
-	generateCmd.PersistentFlags().BoolVar(&createProjectName, "create-project-name", false, "Add different name for each project. Default is false")
-	generateCmd.PersistentFlags().BoolVar(&preserveWorkflows, "preserve-workflows", true, "Preserves workflows from old output files. Default is true")
-	generateCmd.PersistentFlags().BoolVar(&preserveProjects, "preserve-projects", false, "Preserves projects from old output files to enable incremental builds. Default is false")
-	generateCmd.PersistentFlags().BoolVar(&cascadeDependencies, "cascade-dependencies", true, "When true, dependencies will cascade, meaning that a module will be declared to depend not only on its dependencies, but all dependencies of its dependencies all the way down. Default is true")
-	generateCmd.PersistentFlags().StringVar(&defaultWorkflow, "workflow", "", "Name of the workflow to be customized in the atlantis server. Default is to not set")
-	generateCmd.PersistentFlags().StringSliceVar(&defaultApplyRequirements, "apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run. Currently the only supported requirements are `approved` and `mergeable`. Can be overridden by locals")
-	generateCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path of the file where configuration will be generated. Default is not to write to file")
-	generateCmd.PersistentFlags().StringSliceVar(&filterPaths, "filter", []string{}, "Comma-separated paths or glob expressions to the directories you want scope down the config for. Default is all files in root.")
-	generateCmd.PersistentFlags().StringVar(&gitRoot, "root", pwd, "Path to the root directory of the git repo you want to build config for. Default is current dir")
-	generateCmd.PersistentFlags().StringVar(&defaultTerraformVersion, "terraform-version", "", "Default terraform version to specify for all modules. Can be overriden by locals")
-	generateCmd.PersistentFlags().Int64Var(&numExecutors, "num-executors", 15, "Number of executors used for parallel generation of projects. Default is 15")
-	generateCmd.PersistentFlags().StringSliceVar(&projectHclFiles, "project-hcl-files", []string{}, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for. Disables the --filter flag")
-	generateCmd.PersistentFlags().BoolVar(&createHclProjectChilds, "create-hcl-project-childs", false, "Creates Atlantis projects for terragrunt child modules below the directories containing the HCL files defined in --project-hcl-files")
-	generateCmd.PersistentFlags().BoolVar(&createHclProjectExternalChilds, "create-hcl-project-external-childs", true, "Creates Atlantis projects for terragrunt child modules outside the directories containing the HCL files defined in --project-hcl-files")
-	generateCmd.PersistentFlags().BoolVar(&useProjectMarkers, "use-project-markers", false, "Creates Atlantis projects only for project hcl files with locals: atlantis_project = true")
-	generateCmd.PersistentFlags().BoolVar(&executionOrderGroups, "execution-order-groups", false, "Computes execution_order_groups for projects")
-	generateCmd.PersistentFlags().BoolVar(&dependsOn, "depends-on", false, "Computes depends_on for projects. Requires --create-project-name.")
+	addGenerateFlags(generateCmd.PersistentFlags(), pwd)
+}
+
+// A bool flag that leaves its target nil until it is passed, so unset can be told apart from false
+type optionalBoolFlag struct {
+	target **bool
+}
+
+func (flag optionalBoolFlag) String() string {
+	if flag.target == nil || *flag.target == nil {
+		return ""
+	}
+	return strconv.FormatBool(**flag.target)
+}
+
+func (flag optionalBoolFlag) Set(value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*flag.target = &parsed
+	return nil
+}
+
+func (flag optionalBoolFlag) Type() string {
+	return "bool"
+}
+
+// Registers an optionalBoolFlag, which like any bool flag may be passed without a value
+func optionalBoolVar(flags *pflag.FlagSet, target **bool, name string, usage string) {
+	flags.Var(optionalBoolFlag{target: target}, name, usage)
+	flags.Lookup(name).NoOptDefVal = "true"
+}
+
+// Registers the flags that control config generation. They are shared by every command that runs the generator
+func addGenerateFlags(flags *pflag.FlagSet, pwd string) {
+	opts := &generateOptions
+	defaults := generator.DefaultOptions()
+
+	flags.BoolVar(&opts.AutoPlan, "autoplan", defaults.AutoPlan, "Enable auto plan. Default is disabled")
+	flags.BoolVar(&opts.AutoMerge, "automerge", defaults.AutoMerge, "Enable auto merge. Default is disabled")
+	flags.BoolVar(&opts.IgnoreParentTerragrunt, "ignore-parent-terragrunt", defaults.IgnoreParentTerragrunt, "Ignore parent terragrunt configs (those which don't reference a terraform module). Default is enabled")
+	flags.BoolVar(&opts.CreateParentProject, "create-parent-project", defaults.CreateParentProject, "Create a project for the parent terragrunt configs (those which don't reference a terraform module). Default is disabled")
+	flags.BoolVar(&opts.IgnoreDependencyBlocks, "ignore-dependency-blocks", defaults.IgnoreDependencyBlocks, "When true, dependencies found in `dependency` blocks will be ignored")
+	flags.BoolVar(&opts.Parallel, "parallel", defaults.Parallel, "Enables plans and applys to happen in parallel. Default is enabled")
+	flags.BoolVar(&opts.CreateWorkspace, "create-workspace", defaults.CreateWorkspace, "Use different workspace for each project. Default is use default workspace")
+	flags.BoolVar(&opts.CreateProjectName, "create-project-name", defaults.CreateProjectName, "Add different name for each project. Default is false")
+	flags.BoolVar(&opts.PreserveWorkflows, "preserve-workflows", defaults.PreserveWorkflows, "Preserves workflows from old output files. Default is true")
+	flags.BoolVar(&opts.PreserveProjects, "preserve-projects", defaults.PreserveProjects, "Preserves projects from old output files to enable incremental builds. Default is false")
+	flags.StringVar(&opts.PreserveMatch, "preserve-match", defaults.PreserveMatch, "How a generated project finds the preserved project it replaces. One of `dir-workspace-name`, `dir-workspace` or `dir`. Preserved projects marked with a `# managed: false` comment are never touched")
+	flags.StringVar(&opts.ChangedSince, "changed-since", defaults.ChangedSince, "Git ref. Only regenerates the modules affected by the files changed between the ref and the working tree, and merges them into the config at --output. Projects of deleted modules are dropped. Only reads the local repository")
+	flags.BoolVar(&opts.PrunePreserved, "prune-preserved", defaults.PrunePreserved, "Removes the preserved projects whose dir has no terragrunt config left, or whose module is now skipped through atlantis_skip or an exclude block. Each removal is logged")
+	flags.BoolVar(&opts.PruneDryRun, "prune-dry-run", defaults.PruneDryRun, "Only logs the projects --prune-preserved would remove, without writing the config")
+	flags.BoolVar(&opts.CascadeDependencies, "cascade-dependencies", defaults.CascadeDependencies, "When true, dependencies will cascade, meaning that a module will be declared to depend not only on its dependencies, but all dependencies of its dependencies all the way down. Default is true")
+	flags.StringVar(&opts.DefaultWorkflow, "workflow", defaults.DefaultWorkflow, "Name of the workflow to be customized in the atlantis server. Default is to not set")
+	flags.StringSliceVar(&opts.DefaultApplyRequirements, "apply-requirements", defaults.DefaultApplyRequirements, "Requirements that must be satisfied before `atlantis apply` can be run. Currently the only supported requirements are `approved` and `mergeable`. Can be overridden by locals")
+	flags.StringVar(&opts.OutputPath, "output", defaults.OutputPath, "Path of the file where configuration will be generated. Default is not to write to file")
+	flags.StringSliceVar(&opts.FilterPaths, "filter", defaults.FilterPaths, "Comma-separated paths or glob expressions to the directories you want scope down the config for. Default is all files in root.")
+	flags.StringVar(&configFilePath, "config", "", "Path of a YAML file setting any of these flags by name. Flags passed on the command line take precedence. Default is "+defaultConfigFileName+" at --root, if it exists")
+	flags.StringVar(&opts.GitRoot, "root", pwd, "Path to the root directory of the git repo you want to build config for. Default is current dir")
+	flags.StringVar(&opts.DefaultTerraformVersion, "terraform-version", defaults.DefaultTerraformVersion, "Default terraform version to specify for all modules. Can be overriden by locals")
+	flags.Int64Var(&opts.NumExecutors, "num-executors", defaults.NumExecutors, "Number of executors used for parallel generation of projects. Default is 15")
+	flags.StringSliceVar(&opts.ProjectHclFiles, "project-hcl-files", defaults.ProjectHclFiles, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for. Disables the --filter flag")
+	flags.BoolVar(&opts.CreateHclProjectChilds, "create-hcl-project-childs", defaults.CreateHclProjectChilds, "Creates Atlantis projects for terragrunt child modules below the directories containing the HCL files defined in --project-hcl-files")
+	flags.BoolVar(&opts.CreateHclProjectExternalChilds, "create-hcl-project-external-childs", defaults.CreateHclProjectExternalChilds, "Creates Atlantis projects for terragrunt child modules outside the directories containing the HCL files defined in --project-hcl-files")
+	flags.BoolVar(&opts.UseProjectMarkers, "use-project-markers", defaults.UseProjectMarkers, "Creates Atlantis projects only for project hcl files with locals: atlantis_project = true")
+	flags.BoolVar(&opts.ExecutionOrderGroups, "execution-order-groups", defaults.ExecutionOrderGroups, "Computes execution_order_groups for projects. Can't be combined with rules setting execution_order_group")
+	flags.BoolVar(&opts.DependsOn, "depends-on", defaults.DependsOn, "Computes depends_on for projects. Requires --create-project-name.")
+	flags.BoolVar(&opts.DependsOnReduce, "depends-on-reduce", defaults.DependsOnReduce, "Leaves out of depends_on the projects already depended on through another project of the list. The order of the projects doesn't change")
+	optionalBoolVar(flags, &opts.ParallelPlan, "parallel-plan", "Enables plans to happen in parallel. Default is the value of --parallel")
+	optionalBoolVar(flags, &opts.ParallelApply, "parallel-apply", "Enables applies to happen in parallel. Default is the value of --parallel")
+	optionalBoolVar(flags, &opts.AbortOnExecutionOrderFail, "abort-on-execution-order-fail", "Sets abort_on_execution_order_fail, stopping later execution order groups once one fails. Default is to not set")
+	optionalBoolVar(flags, &opts.DeleteSourceBranchOnMerge, "delete-source-branch-on-merge", "Sets delete_source_branch_on_merge for the whole repo. Default is to not set")
+	flags.StringSliceVar(&opts.AllowedRegexpPrefixes, "allowed-regexp-prefixes", defaults.AllowedRegexpPrefixes, "Comma-separated prefixes that `atlantis plan/apply -p` regexps must start with. Default is to not set")
+	flags.StringVar(&opts.Autodiscover, "autodiscover", defaults.Autodiscover, "Sets the autodiscover mode. One of: auto, enabled, disabled. Default is to not set")
+	flags.StringArrayVar(&opts.Rules, "rule", defaults.Rules, "Rule in the format GLOB:KEY=VALUE;KEY=VALUE setting workflow, apply_requirements, plan_requirements, autoplan, terraform_version or execution_order_group for the projects whose dir matches GLOB. Can be repeated, later rules win. Locals take precedence")
+	flags.BoolVar(&opts.SafeMode, "safe-mode", defaults.SafeMode, "Replaces run_cmd, sops_decrypt_file and the get_aws_* functions by stubs while parsing, so configs from untrusted branches can't run commands or use the credentials of this machine. Locals computed from them are reported and ignored")
+	flags.BoolVar(&opts.CleanEnv, "clean-env", defaults.CleanEnv, "Hides the environment of this process from get_env while parsing, so it only sees the variables of --env-file and --env")
+	flags.StringArrayVar(&opts.EnvFiles, "env-file", defaults.EnvFiles, "File of KEY=VALUE lines added to the environment get_env sees while parsing. Can be repeated, later files win")
+	flags.StringArrayVar(&opts.Env, "env", defaults.Env, "Variable in the format KEY=VALUE added to the environment get_env sees while parsing. Can be repeated, wins over --env-file")
+	flags.BoolVar(&opts.AllowCycles, "allow-cycles", defaults.AllowCycles, "Warns about dependency cycles between modules instead of failing, and breaks each by ignoring the dependency that closes it")
+	flags.StringVar(&opts.CacheDir, "cache-dir", defaults.CacheDir, "Dir caching the dependencies and locals of every unit across runs. Entries are only used while the files, env variables and flags the unit was evaluated with are unchanged. Runs may share the dir")
+	flags.BoolVar(&opts.CacheStats, "cache-stats", defaults.CacheStats, "Logs how many units were found in --cache-dir once the config is generated")
+	flags.BoolVar(&opts.ExpandStacks, "expand-stacks", defaults.ExpandStacks, "Creates a project for every unit a terragrunt.stack.hcl file generates below .terragrunt-stack, instead of one for the stack. Each depends on the stack files, the values they read and the catalog dir of the unit")
+	flags.StringArrayVar(&opts.Matrix, "matrix", defaults.Matrix, "Axis in the format KEY=VALUE,VALUE. Every unit is evaluated once per combination of the values of all axes, with KEY set for get_env, and gets a project per combination. Can be repeated")
+	flags.StringArrayVar(&opts.SafeModeFixtures, "safe-mode-fixture", defaults.SafeModeFixtures, "Value in the format FUNCTION=VALUE returned by a function stubbed in safe mode, e.g. get_aws_account_id=123456789012. Can be repeated. Stubs without a fixture return an unknown value")
+	flags.StringSliceVar(&opts.AllowedOverrideKeys, "allowed-override-keys", defaults.AllowedOverrideKeys, "Comma-separated project keys the atlantis_project_overrides local may set. Default is to allow every key")
 }
 
 // Runs a set of arguments, returning the output
@@ -9,8 +9,10 @@ import (
 	"testing"
 
 	"github.com/ghodss/yaml"
+	"github.com/piotrplenik/terragrunt-atlantis-config/pkg/generator"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/sync/singleflight"
 )
 
 // Resets all flag values to their defaults in between tests
@@ -20,32 +22,23 @@ func resetForRun() error {
 		return err
 	}
 
-	// reset caches
-	getDependenciesCache = newGetDependenciesCache()
-	requestGroup = singleflight.Group{}
-	// reset flags
-	gitRoot = pwd
-	autoPlan = false
-	autoMerge = false
-	cascadeDependencies = true
-	ignoreParentTerragrunt = true
-	ignoreDependencyBlocks = false
-	parallel = true
-	createWorkspace = false
-	createProjectName = false
-	preserveWorkflows = true
-	preserveProjects = true
-	defaultWorkflow = ""
-	filterPaths = []string{}
-	outputPath = ""
-	defaultTerraformVersion = ""
-	defaultApplyRequirements = []string{}
-	projectHclFiles = []string{}
-	createHclProjectChilds = false
-	createHclProjectExternalChilds = true
-	useProjectMarkers = false
-	executionOrderGroups = false
-	dependsOn = false
+	// Forget the flags earlier tests passed, as the config file only sets the flags that weren't. `--depends-on`
+	// marks `--create-project-name` as required for good, so that is forgotten as well
+	configFilePath = ""
+	for _, command := range rootCmd.Commands() {
+		for _, flags := range []*pflag.FlagSet{command.Flags(), command.PersistentFlags()} {
+			flags.VisitAll(func(flag *pflag.Flag) {
+				flag.Changed = false
+				delete(flag.Annotations, cobra.BashCompOneRequiredFlag)
+			})
+		}
+	}
+
+	// reset flags. Caches live in each generator run, so there is nothing else to reset. The tests run against
+	// the dir given by `--root` and preserve the projects of existing output files unless told otherwise
+	generateOptions = generator.DefaultOptions()
+	generateOptions.GitRoot = pwd
+	generateOptions.PreserveProjects = true
 
 	return nil
 }
@@ -69,7 +62,7 @@ func runTest(t *testing.T, goldenFile string, args []string) {
 	}, args...)
 
 	contentBytes, err := RunWithFlags(filename, allArgs)
-	content := &AtlantisConfig{}
+	content := &generator.AtlantisConfig{}
 	yaml.Unmarshal(contentBytes, content)
 	if err != nil {
 		t.Error(err)
@@ -77,7 +70,7 @@ func runTest(t *testing.T, goldenFile string, args []string) {
 	}
 
 	goldenContentsBytes, err := os.ReadFile(goldenFile)
-	goldenContents := &AtlantisConfig{}
+	goldenContents := &generator.AtlantisConfig{}
 	yaml.Unmarshal(goldenContentsBytes, goldenContents)
 	if err != nil {
 		t.Error("Failed to read golden file")
@@ -435,6 +428,185 @@ func TestEnablingAutomerge(t *testing.T) {
 	})
 }
 
+func TestPreservingUnknownKeys(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file with handwritten keys the generator doesn't model
+	contents := []byte(`abort_on_execution_order_fail: true
+allowed_regexp_prefixes:
+- dev/
+projects:
+- autoplan:
+    enabled: true
+    when_modified:
+    - '*.tf*'
+  dir: .
+  policy_check: true
+- dir: someDir
+  name: projectFromPreviousRun
+  custom_key:
+    nested: value
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "unknownKeysPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	assert.Equal(t, string(goldenContents), string(content))
+}
+
+func TestRepoSettingsFlags(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_settings.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--parallel-plan",
+		"--parallel-apply=false",
+		"--abort-on-execution-order-fail",
+		"--delete-source-branch-on-merge=false",
+		"--allowed-regexp-prefixes",
+		"dev/,prod/",
+		"--autodiscover",
+		"disabled",
+	})
+}
+
+func TestConfigFileAtRoot(t *testing.T) {
+	runTest(t, filepath.Join("golden", "config_file.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "config_file"),
+	})
+}
+
+func TestConfigFileOverriddenByFlags(t *testing.T) {
+	runTest(t, filepath.Join("golden", "config_file_flags_override.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "config_file"),
+		"--autoplan=false",
+		"--apply-requirements",
+		"approved",
+	})
+}
+
+func TestPathRules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "path_rules.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "path_rules"),
+		"--workflow",
+		"default",
+		"--rule",
+		"prod/**:workflow=prod;apply_requirements=approved,mergeable;autoplan=true",
+		"--rule",
+		"prod/db:terraform_version=1.9.0;execution_order_group=1",
+		"--rule",
+		"*/app:plan_requirements=approved",
+	})
+}
+
+func TestReadFilesAreDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "read_files.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "read_files"),
+	})
+}
+
+func TestTerraformFileReferencesAreDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terraform_file_references.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terraform_file_references"),
+	})
+}
+
+func TestSafeModeIgnoresUnknownLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "safe_mode.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "safe_mode"),
+		"--safe-mode",
+	})
+}
+
+func TestSafeModeFixtures(t *testing.T) {
+	runTest(t, filepath.Join("golden", "safe_mode_fixtures.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "safe_mode"),
+		"--safe-mode",
+		"--safe-mode-fixture",
+		"run_cmd=fixture-workflow",
+	})
+}
+
+func TestEnvFile(t *testing.T) {
+	runTest(t, filepath.Join("golden", "env_vars_staging.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--env-file",
+		filepath.Join("..", "test_examples", "env_vars", "staging.env"),
+	})
+}
+
+func TestEnvFlagOverridesEnvFile(t *testing.T) {
+	runTest(t, filepath.Join("golden", "env_vars_prod.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--env-file",
+		filepath.Join("..", "test_examples", "env_vars", "staging.env"),
+		"--env",
+		"DEPLOY_ENV=prod",
+	})
+}
+
+func TestCleanEnvHidesProcessEnvironment(t *testing.T) {
+	t.Setenv("DEPLOY_ENV", "prod")
+
+	runTest(t, filepath.Join("golden", "env_vars_dev.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--clean-env",
+	})
+}
+
+func TestMatrixCreatesProjectPerVariant(t *testing.T) {
+	runTest(t, filepath.Join("golden", "variants_matrix.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "variants", "matrix"),
+		"--matrix",
+		"TG_ENV=dev,prod",
+		"--execution-order-groups",
+		"--depends-on",
+		"--create-project-name",
+	})
+}
+
+func TestWorkspacesLocalCreatesProjectPerWorkspace(t *testing.T) {
+	runTest(t, filepath.Join("golden", "variants_workspaces.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "variants", "workspaces"),
+	})
+}
+
 func TestChainedDependencies(t *testing.T) {
 	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
 		"--root",
@@ -458,6 +630,46 @@ func TestApplyRequirementsLocals(t *testing.T) {
 	})
 }
 
+func TestProjectSettingsLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "project_settings_locals.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "project_settings_locals"),
+	})
+}
+
+func TestProjectOverridesLocal(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Compares the raw output, as unmodelled keys like `policy_check` are lost when parsing into a config
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "project_overrides"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "project_overrides.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	assert.Equal(t, string(goldenContents), string(content))
+}
+
 func TestApplyRequirementsFlag(t *testing.T) {
 	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
 		"--root",
@@ -690,9 +902,410 @@ func TestStack(t *testing.T) {
 	})
 }
 
+func TestExpandStacksCreatesProjectPerUnit(t *testing.T) {
+	runTest(t, filepath.Join("golden", "stack_expand.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "stack_expand"),
+		"--expand-stacks",
+		"--create-project-name",
+	})
+}
+
+func TestExpandStacksWithRemoteStack(t *testing.T) {
+	runTest(t, filepath.Join("golden", "stack_expand_remote.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "stack"),
+		"--expand-stacks",
+	})
+}
+
+func TestExpandStacksWithProjectHclFiles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "stack_expand_project_hcl.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "stack_expand_project_hcl"),
+		"--expand-stacks",
+		"--project-hcl-files=env.hcl",
+		"--create-hcl-project-external-childs",
+	})
+}
+
+func TestExcludeBlocksSkipUnitsAndDisabledDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "exclude.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "exclude"),
+		"--execution-order-groups",
+		"--depends-on",
+		"--create-project-name",
+	})
+}
+
+func TestExcludeBlocksEvaluateEnvironment(t *testing.T) {
+	runTest(t, filepath.Join("golden", "exclude_prod.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "exclude"),
+		"--env",
+		"DEPLOY_ENV=prod",
+		"--execution-order-groups",
+		"--depends-on",
+		"--create-project-name",
+	})
+}
+
+func TestAllowCyclesBreaksCycles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "dependency_cycle_allowed.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples_errors", "dependency_cycle"),
+		"--allow-cycles",
+		"--execution-order-groups",
+		"--depends-on",
+		"--create-project-name",
+	})
+}
+
+func TestExecutionOrderGroupsFollowLongestDependencyChain(t *testing.T) {
+	runTest(t, filepath.Join("golden", "execution_order.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "execution_order"),
+		"--execution-order-groups",
+		"--depends-on",
+		"--create-project-name",
+	})
+}
+
+func TestDependsOnReduceKeepsOnlyDirectDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "execution_order_reduced.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "execution_order"),
+		"--execution-order-groups",
+		"--depends-on",
+		"--depends-on-reduce",
+		"--create-project-name",
+	})
+}
+
 func TestValues(t *testing.T) {
 	runTest(t, filepath.Join("golden", "values.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples", "values"),
 	})
 }
+
+func TestCacheDirKeepsOutputUnchanged(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	// The second run finds every unit in the cache
+	for run := 0; run < 2; run++ {
+		runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+			"--root",
+			filepath.Join("..", "test_examples", "chained_dependencies"),
+			"--cascade-dependencies",
+			"--cache-dir",
+			cacheDir,
+			"--cache-stats",
+		})
+	}
+}
+
+// An existing config with projects for modules that are skipped now, or were deleted
+var preservedProjectsToPrune = []byte(`projects:
+- dir: skip_true
+  name: skippedNow
+- dir: set_in_parent
+  name: skippedThroughParent
+- dir: deleted
+  name: deletedModule
+- dir: skip_false
+  name: stillGenerated
+`)
+
+func TestPruningPreservedProjects(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+	os.WriteFile(filename, preservedProjectsToPrune, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--prune-preserved",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "skip"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "preservedProjectsPruned.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	assert.Equal(t, string(goldenContents), string(content))
+}
+
+func TestPruneDryRunDoesNotWrite(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+	os.WriteFile(filename, preservedProjectsToPrune, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--prune-preserved",
+		"--prune-dry-run",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "skip"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, string(preservedProjectsToPrune), string(content))
+}
+
+// An existing config with several projects for the same dir, one of them written by hand
+var preservedProjectsSharingADir = []byte(`projects:
+# managed: false
+- dir: .
+  name: handwritten
+  workspace: staging
+  workflow: custom
+- dir: .
+  name: blue
+  policy_check: true
+- dir: .
+  policy_check: false
+`)
+
+// An existing config with a project for a dir generated in another workspace
+var preservedProjectInAnotherWorkspace = []byte(`projects:
+- dir: .
+  workspace: legacy
+  policy_check: true
+`)
+
+// Runs generate over an existing config, asserting the output matches a golden file
+func runPreserveMatchTest(t *testing.T, contents []byte, goldenFile string, args []string) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, append([]string{
+		"generate",
+		"--preserve-projects",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	}, args...))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	goldenContents, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	assert.Equal(t, string(goldenContents), string(content))
+}
+
+func TestPreserveMatchByDirWorkspaceAndName(t *testing.T) {
+	runPreserveMatchTest(t, preservedProjectsSharingADir, filepath.Join("golden", "preserveMatchDirWorkspaceName.yaml"), []string{})
+}
+
+func TestPreserveMatchByDir(t *testing.T) {
+	runPreserveMatchTest(t, preservedProjectInAnotherWorkspace, filepath.Join("golden", "preserveMatchDir.yaml"), []string{
+		"--preserve-match",
+		"dir",
+	})
+}
+
+func TestInvalidInputFails(t *testing.T) {
+	for name, tc := range map[string]struct {
+		oldConfig []byte
+		args      []string
+		err       string
+	}{
+		"repo setting conflicting with the old config": {
+			oldConfig: []byte("abort_on_execution_order_fail: true\n"),
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "basic_module"),
+				"--abort-on-execution-order-fail=false",
+			},
+			err: "abort_on_execution_order_fail is set to false, but",
+		},
+		"unknown key in the config file": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "config_file_invalid"),
+				"--config",
+				filepath.Join("..", "test_examples", "config_file_invalid", "settings.yaml"),
+			},
+			err: `unknown key "create_project_name", did you mean "create-project-name"?`,
+		},
+		"unknown key in a rule": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "path_rules"),
+				"--rule",
+				"prod/**:workflows=prod",
+			},
+			err: `unknown key "workflows" in rule "prod/**:workflows=prod"`,
+		},
+		"rule setting an execution order group together with computed groups": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "path_rules"),
+				"--rule",
+				"prod/db:execution_order_group=1",
+				"--execution-order-groups",
+			},
+			err: `rule "prod/db:execution_order_group=1" sets execution_order_group, which can't be combined with computed execution order groups`,
+		},
+		"safe mode fixture for a function that is not stubbed": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "safe_mode"),
+				"--safe-mode",
+				"--safe-mode-fixture",
+				"get_env=prod",
+			},
+			err: `invalid safe mode fixture "get_env=prod", get_env isn't stubbed in safe mode`,
+		},
+		"matrix without values": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "variants", "matrix"),
+				"--matrix",
+				"TG_ENV=",
+			},
+			err: `invalid matrix "TG_ENV=", expected KEY=VALUE,VALUE`,
+		},
+		"matrix key set twice": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "variants", "matrix"),
+				"--matrix",
+				"TG_ENV=dev",
+				"--matrix",
+				"TG_ENV=prod",
+			},
+			err: "matrix key TG_ENV is set more than once",
+		},
+		"project overrides outside the allowed keys": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "project_overrides"),
+				"--allowed-override-keys",
+				"autoplan,plan_requirements",
+			},
+			err: `atlantis_project_overrides sets "policy_check"`,
+		},
+		"dependency cycle when cascading": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples_errors", "dependency_cycle"),
+			},
+			err: "dependency cycle: app/terragrunt.hcl -(dependency)-> database/terragrunt.hcl -(dependency)-> network/terragrunt.hcl -(dependency)-> app/terragrunt.hcl",
+		},
+		"dependency cycle when not cascading": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples_errors", "dependency_cycle"),
+				"--cascade-dependencies=false",
+				"--execution-order-groups",
+			},
+			err: "dependency cycle: app/terragrunt.hcl -(dependency)-> database/terragrunt.hcl -(dependency)-> network/terragrunt.hcl -(dependency)-> app/terragrunt.hcl",
+		},
+		"cache stats without a cache dir": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "basic_module"),
+				"--cache-stats",
+			},
+			err: "cache stats have no effect unless a cache dir is set",
+		},
+		"changed since without an earlier config": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "basic_module"),
+				"--changed-since",
+				"HEAD",
+			},
+			err: "incremental generation needs the config of an earlier run at test_artifacts",
+		},
+		"pruning without preserved projects": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "skip"),
+				"--preserve-projects=false",
+				"--prune-preserved",
+			},
+			err: "pruning preserved projects has no effect unless projects are preserved",
+		},
+		"unknown preserve match strategy": {
+			args: []string{
+				"--root",
+				filepath.Join("..", "test_examples", "basic_module"),
+				"--preserve-match",
+				"name",
+			},
+			err: `unknown preserve match strategy "name"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := resetForRun()
+			if err != nil {
+				t.Error("Failed to reset default flags")
+				return
+			}
+
+			randomInt := rand.Int()
+			filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+			defer os.Remove(filename)
+			if tc.oldConfig != nil {
+				os.WriteFile(filename, tc.oldConfig, 0644)
+			}
+
+			_, err = RunWithFlags(filename, append([]string{
+				"generate",
+				"--output",
+				filename,
+			}, tc.args...))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
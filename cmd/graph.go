@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/spf13/cobra"
+
+	"github.com/piotrplenik/terragrunt-atlantis-config/pkg/generator"
+)
+
+var graphFormat string
+
+// Renders a graph in the format given by `--format`
+func renderGraph(graph *generator.Graph, graphFormat string) (string, error) {
+	switch graphFormat {
+	case "dot":
+		return graph.DOT(), nil
+	case "mermaid":
+		return graph.Mermaid(), nil
+	case "json":
+		bytes, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(bytes) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown graph format %q, expected one of: dot, mermaid, json", graphFormat)
+	}
+}
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Exports the dependency graph of all projects",
+	Long:  `Prints the projects and the files they depend on as a graph in Graphviz DOT, Mermaid or JSON format`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := options.NewTerragruntOptions()
+
+		l := log.New(
+			log.WithOutput(opts.ErrWriter),
+			log.WithLevel(options.DefaultLogLevel),
+			log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())),
+		)
+
+		generatorOptions := generateOptions
+		generatorOptions.Logger = l
+
+		graph, err := generator.BuildGraph(cmd.Context(), generatorOptions)
+		if err != nil {
+			return err
+		}
+
+		rendered, err := renderGraph(graph, graphFormat)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), rendered)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	addGenerateFlags(graphCmd.PersistentFlags(), pwd)
+	graphCmd.PersistentFlags().StringVar(&graphFormat, "format", "dot", "Output format of the graph. One of: dot, mermaid, json")
+}
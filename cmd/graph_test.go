@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs the graph command, asserting the output matches a golden file
+func runGraphTest(t *testing.T, goldenFile string, args []string) {
+	err := resetForRun()
+	if err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+	defer func() { graphFormat = "dot" }()
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	defer rootCmd.SetOut(nil)
+
+	rootCmd.SetArgs(append([]string{"graph"}, args...))
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goldenContents, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal("Failed to read golden file")
+	}
+
+	assert.Equal(t, string(goldenContents), out.String())
+}
+
+func TestGraphDOT(t *testing.T) {
+	runGraphTest(t, filepath.Join("golden", "graph_chained_dependencies.dot"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+	})
+}
+
+func TestGraphIgnoringDependencyBlocks(t *testing.T) {
+	runGraphTest(t, filepath.Join("golden", "graph_dependencies_ignored.dot"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--ignore-dependency-blocks",
+	})
+}
+
+func TestGraphMermaid(t *testing.T) {
+	runGraphTest(t, filepath.Join("golden", "graph_extra_arguments.mmd"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "extra_arguments"),
+		"--format",
+		"mermaid",
+	})
+}
+
+func TestGraphJSON(t *testing.T) {
+	runGraphTest(t, filepath.Join("golden", "graph_local_terraform_module.json"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "local_terraform_module_source"),
+		"--format",
+		"json",
+	})
+}
@@ -16,6 +16,9 @@ var rootCmd = &cobra.Command{
 	Short:        "Generates Atlantis Config for Terragrunt projects",
 	Long:         "Generates Atlantis Config for Terragrunt projects",
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfigFile(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
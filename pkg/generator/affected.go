@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+)
+
+// Why a change affects a project
+type AffectedReason string
+
+const (
+	// A changed file matches one of the project's `when_modified` patterns
+	AffectedModified AffectedReason = "modified"
+
+	// The project depends, through `depends_on`, on another affected project
+	AffectedDependent AffectedReason = "dependent"
+)
+
+// A project impacted by a set of changed files
+type AffectedProject struct {
+	Dir       string         `json:"dir"`
+	Name      string         `json:"name,omitempty"`
+	Workspace string         `json:"workspace,omitempty"`
+	Reason    AffectedReason `json:"reason"`
+
+	// The changed file that matched, or the name of the affected project this one depends on
+	Cause string `json:"cause"`
+}
+
+// Builds a matcher for the `when_modified` patterns of a project. Patterns are relative to the
+// project dir, so just like Atlantis does, the dir is prepended to each of them
+func newWhenModifiedMatcher(project AtlantisProject) (*patternmatcher.PatternMatcher, error) {
+	patterns := []string{}
+	for _, pattern := range project.Autoplan.WhenModified {
+		pattern = strings.TrimSpace(pattern)
+
+		// An exclusion starts with a '!', which has to stay in front of the project dir
+		exclusion := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		pattern = filepath.Join(project.Dir, pattern)
+		if exclusion {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patternmatcher.New(patterns)
+}
+
+// Returns the first of `files` that matches the `when_modified` patterns of the project, or "" if none
+// does. Files are relative to the repo root
+func (project AtlantisProject) MatchingFile(files []string) (string, error) {
+	matcher, err := newWhenModifiedMatcher(project)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		match, err := matcher.MatchesOrParentMatches(filepath.Clean(file))
+		if err != nil {
+			return "", err
+		}
+		if match {
+			return file, nil
+		}
+	}
+
+	return "", nil
+}
+
+// AffectedProjects lists the projects of `config` impacted by the changed `files`, which are relative to the
+// repo root. With `includeDependents`, projects depending on an affected project through `depends_on` are
+// added as well, all the way down
+func AffectedProjects(config *AtlantisConfig, files []string, includeDependents bool) ([]AffectedProject, error) {
+	affected := map[int]AffectedProject{}
+	queue := []int{}
+
+	for i, project := range config.Projects {
+		file, err := project.MatchingFile(files)
+		if err != nil {
+			return nil, err
+		}
+		if file == "" {
+			continue
+		}
+
+		affected[i] = AffectedProject{
+			Dir:       project.Dir,
+			Name:      project.Name,
+			Workspace: project.Workspace,
+			Reason:    AffectedModified,
+			Cause:     file,
+		}
+		queue = append(queue, i)
+	}
+
+	// Walk the `depends_on` edges in reverse, from each affected project to the projects depending on it
+	for includeDependents && len(queue) > 0 {
+		current := config.Projects[queue[0]]
+		queue = queue[1:]
+		if current.Name == "" {
+			continue
+		}
+
+		for i, project := range config.Projects {
+			if _, ok := affected[i]; ok {
+				continue
+			}
+
+			for _, dependency := range project.DependsOn {
+				if dependency == current.Name {
+					affected[i] = AffectedProject{
+						Dir:       project.Dir,
+						Name:      project.Name,
+						Workspace: project.Workspace,
+						Reason:    AffectedDependent,
+						Cause:     current.Name,
+					}
+					queue = append(queue, i)
+					break
+				}
+			}
+		}
+	}
+
+	// Keep the order of the projects in the config
+	indexes := []int{}
+	for i := range affected {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	projects := []AffectedProject{}
+	for _, i := range indexes {
+		projects = append(projects, affected[i])
+	}
+
+	return projects, nil
+}
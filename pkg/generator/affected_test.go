@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchingFileUsesAtlantisSemantics(t *testing.T) {
+	project := AtlantisProject{
+		Dir: "prod/vpc",
+		Autoplan: AutoplanConfig{
+			WhenModified: []string{
+				"terragrunt.hcl",
+				"*.tf*",
+				"../../modules/vpc/**/*.tf",
+				"!*.tfvars",
+			},
+		},
+	}
+
+	cases := map[string]bool{
+		"prod/vpc/terragrunt.hcl":          true,
+		"prod/vpc/main.tf":                 true,
+		"prod/vpc/dev.tfvars":              false,
+		"prod/vpc/nested/main.tf":          false,
+		"modules/vpc/main.tf":              true,
+		"modules/vpc/submodule/main.tf":    true,
+		"prod/vpc-peering/terragrunt.hcl":  false,
+		"modules/vpc/README.md":            false,
+		"./prod/vpc/../vpc/terragrunt.hcl": true,
+	}
+
+	for file, expected := range cases {
+		match, err := project.MatchingFile([]string{file})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, match != "", file)
+	}
+}
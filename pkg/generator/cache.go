@@ -0,0 +1,525 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Bumped whenever what's cached or how it's computed changes, so entries written by older versions are never read
+const parseCacheVersion = 1
+
+// Calls of functions whose result depends on more than the files read and the environment. Units calling them are
+// never cached
+var nonDeterministicCalls = regexp.MustCompile(`\b(timestamp|uuid|bcrypt)\s*\(`)
+
+// Calls of the unsafe functions, which only give the same result every time when safe mode stubs them
+var unsafeCalls = regexp.MustCompile(`\b(` + strings.Join(unsafeFunctions, "|") + `|get_aws_[a-z_]+)\s*\(`)
+
+// Stores the direct dependencies and locals of units in `opts.CacheDir`, so later runs don't have to parse units
+// whose inputs haven't changed. It's shared by the generators of all variants of a run
+type parseCache struct {
+	dir string
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	invalidated atomic.Int64
+	uncacheable atomic.Int64
+}
+
+// A cached unit. Entries are looked up by a key derived from the unit file, and only used when all their inputs
+// are still the same
+type parseCacheEntry struct {
+	Version int              `json:"version"`
+	Inputs  parseCacheInputs `json:"inputs"`
+	Output  cachedUnit       `json:"output"`
+}
+
+// Everything the result of parsing a unit was computed from, besides the unit file and the options in the key
+type parseCacheInputs struct {
+	// Content hashes of the files the unit was computed from, by absolute path. Files that didn't exist, but would
+	// have been found by `find_in_parent_folders` or read otherwise if they did, have an empty hash
+	Files map[string]string `json:"files"`
+
+	// Hashes of the terraform files of the scanned module dirs, by absolute path. Dirs that didn't exist, but decided
+	// the files a module reads if they did, have an empty hash
+	ModuleDirs map[string]string `json:"module_dirs"`
+
+	// Values of the variables read through `get_env`. Variables that weren't set are nil
+	Env map[string]*string `json:"env"`
+}
+
+// The serialized form of `getDependenciesOutput`
+type cachedUnit struct {
+	Dependencies  []string             `json:"dependencies"`
+	ModuleFiles   []string             `json:"module_files"`
+	Excluded      bool                 `json:"excluded"`
+	Includes      []string             `json:"includes"`
+	Edges         []Edge               `json:"edges"`
+	Locals        ResolvedLocals       `json:"locals"`
+	MarkedProject *bool                `json:"marked_project"`
+	UnknownLocals []cachedUnknownLocal `json:"unknown_locals"`
+}
+
+type cachedUnknownLocal struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func newParseCache(dir string) (*parseCache, error) {
+	absoluteDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absoluteDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &parseCache{dir: absoluteDir}, nil
+}
+
+// Finds the direct dependencies of the unit at `path` in the cache, or else resolves and caches them
+func (g *generator) loadDirectDependencies(ctx context.Context, log log.Logger, path string) (getDependenciesOutput, error) {
+	if g.cache == nil {
+		return g.resolveDirectDependencies(ctx, log, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+	key := g.parseCacheKey(path, content)
+
+	entry, found := g.cache.load(key)
+	if found && g.parseCacheInputsUpToDate(entry.Inputs) {
+		g.cache.hits.Add(1)
+		log.Debugf("Parse cache hit for %s", path)
+		return entry.Output.restore(), nil
+	}
+	g.cache.misses.Add(1)
+	if found {
+		g.cache.invalidated.Add(1)
+		log.Debugf("Parse cache entry of %s is out of date", path)
+	}
+
+	output, err := g.resolveDirectDependencies(ctx, log, path)
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+
+	unit, err := g.parseUnit(ctx, log, path)
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+	inputs, cacheable, err := g.collectParseCacheInputs(path, unit, output)
+	if err != nil {
+		log.Debugf("Not caching %s: %v", path, err)
+		cacheable = false
+	}
+	if !cacheable {
+		g.cache.uncacheable.Add(1)
+		return output, nil
+	}
+
+	g.cache.store(log, key, parseCacheEntry{Version: parseCacheVersion, Inputs: inputs, Output: newCachedUnit(output)})
+	return output, nil
+}
+
+// Derives the key of the unit at `path` from its content and everything else that changes how it's evaluated
+func (g *generator) parseCacheKey(path string, content []byte) string {
+	fixtures := slices.Clone(g.opts.SafeModeFixtures)
+	sort.Strings(fixtures)
+
+	fields := []string{
+		strconv.Itoa(parseCacheVersion),
+		buildFingerprint(),
+		g.gitRoot,
+		filepath.Clean(path),
+		hashBytes(content),
+		strconv.FormatBool(g.opts.IgnoreParentTerragrunt),
+		strconv.FormatBool(g.opts.IgnoreDependencyBlocks),
+		strconv.FormatBool(g.opts.SafeMode),
+		strings.Join(fixtures, "\x00"),
+	}
+	if g.variant != nil {
+		fields = append(fields, g.variant.name, g.variant.workspace)
+		keys := []string{}
+		for key := range g.variant.env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fields = append(fields, key+"="+g.variant.env[key])
+		}
+	}
+
+	return hashBytes([]byte(strings.Join(fields, "\x00")))
+}
+
+// Identifies the build of this tool and of terragrunt, as another version could parse units differently
+func buildFingerprint() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	parts := []string{info.Main.Version}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+			parts = append(parts, setting.Value)
+		}
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/gruntwork-io/terragrunt" {
+			parts = append(parts, dep.Version)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (c *parseCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Reads the entry of `key`. Entries that can't be read are treated as missing
+func (c *parseCache) load(key string) (*parseCacheEntry, bool) {
+	content, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &parseCacheEntry{}
+	if err := json.Unmarshal(content, entry); err != nil || entry.Version != parseCacheVersion {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// Writes the entry of `key`. The entry is written to a temporary file that's renamed into place, so runs sharing the
+// cache dir only ever see complete entries. Failing to write only costs the next run a parse, so it isn't an error
+func (c *parseCache) store(log log.Logger, key string, entry parseCacheEntry) {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		log.Debugf("Can't encode the parse cache entry %s: %v", key, err)
+		return
+	}
+
+	path := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Debugf("Can't write the parse cache entry %s: %v", key, err)
+		return
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		log.Debugf("Can't write the parse cache entry %s: %v", key, err)
+		return
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		log.Debugf("Can't write the parse cache entry %s: %v", key, err)
+	}
+}
+
+// Logs how many units were found in the cache
+func (c *parseCache) logStats(log log.Logger) {
+	log.Infof(
+		"Parse cache: %d hits, %d misses (%d out of date), %d not cacheable",
+		c.hits.Load(), c.misses.Load(), c.invalidated.Load(), c.uncacheable.Load(),
+	)
+}
+
+// Collects the inputs of a parsed unit. Units calling functions whose result can change without any input changing
+// aren't cacheable
+func (g *generator) collectParseCacheInputs(path string, unit *parsedUnit, output getDependenciesOutput) (parseCacheInputs, bool, error) {
+	inputs := parseCacheInputs{
+		Files:      map[string]string{},
+		ModuleDirs: map[string]string{},
+		Env:        unit.envRead,
+	}
+
+	// The unit, the configs it includes and the files read while parsing them
+	evaluated := []string{filepath.Clean(path)}
+	for _, include := range output.includes {
+		evaluated = append(evaluated, filepath.Clean(filepath.FromSlash(include)))
+	}
+	evaluated = append(evaluated, unit.filesRead...)
+	for _, file := range evaluated {
+		content, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			inputs.Files[file] = ""
+			continue
+		}
+		if err != nil {
+			return inputs, false, err
+		}
+		if nonDeterministicCalls.Match(content) || (!g.opts.SafeMode && unsafeCalls.Match(content)) {
+			return inputs, false, nil
+		}
+		inputs.Files[file] = hashBytes(content)
+	}
+
+	// The values files of the unit and of the configs it includes
+	others := []string{}
+	for _, file := range evaluated[:len(output.includes)+1] {
+		others = append(others, filepath.Join(filepath.Dir(file), "terragrunt.values.hcl"))
+	}
+
+	// The files that would have been found instead by `find_in_parent_folders`, had they existed
+	others = append(others, g.shadowingFiles(filepath.Dir(path), evaluated[1:])...)
+
+	for _, file := range others {
+		if _, ok := inputs.Files[file]; ok {
+			continue
+		}
+		hash, err := hashFile(file)
+		if err != nil {
+			return inputs, false, err
+		}
+		inputs.Files[file] = hash
+	}
+
+	for _, dir := range output.moduleDirs {
+		hash, err := hashModuleDir(dir)
+		if err != nil {
+			return inputs, false, err
+		}
+		inputs.ModuleDirs[dir] = hash
+	}
+
+	return inputs, true, nil
+}
+
+// Finds the paths `find_in_parent_folders` looks at before it gets to one of `files`, starting at `unitDir`. For a
+// file at `<ancestor>/<rel>`, those are `<dir>/<rel>` for every dir from `unitDir` up to the ancestor. Only ancestors
+// within the git root are considered
+func (g *generator) shadowingFiles(unitDir string, files []string) []string {
+	ancestors := []string{}
+	for dir := filepath.Dir(unitDir); strings.HasPrefix(dir+string(filepath.Separator), g.gitRoot); dir = filepath.Dir(dir) {
+		ancestors = append(ancestors, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	shadowing := []string{}
+	for _, file := range files {
+		for _, ancestor := range ancestors {
+			rel, err := filepath.Rel(ancestor, file)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			for dir := unitDir; dir != ancestor; dir = filepath.Dir(dir) {
+				shadowing = append(shadowing, filepath.Join(dir, rel))
+			}
+		}
+	}
+
+	return shadowing
+}
+
+// Checks that every input of a cached unit is still the same
+func (g *generator) parseCacheInputsUpToDate(inputs parseCacheInputs) bool {
+	for name, cachedValue := range inputs.Env {
+		value, ok := g.env[name]
+		if ok != (cachedValue != nil) || (ok && value != *cachedValue) {
+			return false
+		}
+	}
+
+	for file, cachedHash := range inputs.Files {
+		hash, err := hashFile(file)
+		if err != nil || hash != cachedHash {
+			return false
+		}
+	}
+
+	for dir, cachedHash := range inputs.ModuleDirs {
+		hash, err := hashModuleDir(dir)
+		if err != nil || hash != cachedHash {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hashBytes(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hashes the content of the file at `path`, or returns an empty hash when it doesn't exist
+func hashFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return hashBytes(content), nil
+}
+
+// Hashes the names and contents of the terraform files in `dir`, which are what module files are found from
+func hashModuleDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), ".tf") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(entry.Name() + "\x00" + hashBytes(content) + "\x00"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// The dirs `parseTerraformLocalModuleSource` scanned to find `sources` for the module at `dir`
+func scannedModuleDirs(dir string, sources []string) []string {
+	dirs := []string{filepath.Clean(dir)}
+	for _, source := range sources {
+		if filepath.Base(source) == "*.tf*" {
+			dirs = append(dirs, filepath.Dir(source))
+		}
+	}
+
+	return dirs
+}
+
+func newCachedUnit(output getDependenciesOutput) cachedUnit {
+	unknownLocals := []cachedUnknownLocal{}
+	for _, local := range output.locals.unknownLocals {
+		unknownLocals = append(unknownLocals, cachedUnknownLocal{Name: local.name, Path: local.path})
+	}
+
+	return cachedUnit{
+		Dependencies:  output.dependencies,
+		ModuleFiles:   output.moduleFiles,
+		Excluded:      output.excluded,
+		Includes:      output.includes,
+		Edges:         output.edges,
+		Locals:        output.locals,
+		MarkedProject: output.locals.markedProject,
+		UnknownLocals: unknownLocals,
+	}
+}
+
+func (c cachedUnit) restore() getDependenciesOutput {
+	locals := c.Locals
+	locals.markedProject = c.MarkedProject
+	for _, local := range c.UnknownLocals {
+		locals.unknownLocals = append(locals.unknownLocals, unknownLocal{name: local.Name, path: local.Path})
+	}
+
+	return getDependenciesOutput{
+		dependencies: c.Dependencies,
+		moduleFiles:  c.ModuleFiles,
+		excluded:     c.Excluded,
+		includes:     c.Includes,
+		edges:        c.Edges,
+		locals:       locals,
+	}
+}
+
+// The variables read through `get_env` while parsing a unit
+type envReads struct {
+	mtx    sync.Mutex
+	values map[string]*string
+}
+
+func newEnvReads() *envReads {
+	return &envReads{values: map[string]*string{}}
+}
+
+func (r *envReads) record(name string, env map[string]string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if value, ok := env[name]; ok {
+		r.values[name] = &value
+	} else {
+		r.values[name] = nil
+	}
+}
+
+func (r *envReads) snapshot() map[string]*string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	values := map[string]*string{}
+	for name, value := range r.values {
+		values[name] = value
+	}
+
+	return values
+}
+
+// Adds a `get_env` recording the variables it reads in `reads` to `functions`, which are the functions replacing
+// terragrunt's own while parsing
+func withTrackedEnv(functions map[string]function.Function, parsingContext *config.ParsingContext, log log.Logger, reads *envReads) map[string]function.Function {
+	tracked := map[string]function.Function{}
+	for name, fn := range functions {
+		tracked[name] = fn
+	}
+
+	tracked[config.FuncNameGetEnv] = function.New(&function.Spec{
+		VarParam: &function.Parameter{Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			params := []string{}
+			for _, arg := range args {
+				params = append(params, arg.AsString())
+			}
+			if len(params) > 0 {
+				reads.record(params[0], parsingContext.TerragruntOptions.Env)
+			}
+
+			value, err := getEnvironmentVariable(parsingContext, log, params)
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+
+	return tracked
+}
@@ -0,0 +1,271 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+// A file that differs between a git ref and the working tree
+type changedFile struct {
+	// Path relative to the git root, with Unix path separators. Files outside of the git root start with `../`
+	path string
+
+	deleted bool
+}
+
+// The units an incremental generation regenerates, and the projects it drops
+type incrementalGeneration struct {
+	// Why each unit is regenerated, by absolute path of its config file
+	reasons map[string]string
+
+	// Why the projects of each dir are dropped, for dirs whose unit was deleted
+	removed map[string]string
+}
+
+// Lists the files that differ between `ref` and the working tree of the git repository containing `gitRoot`,
+// including untracked files that aren't ignored. Only the local repository is read
+func gitChangedFiles(gitRoot string, ref string) ([]changedFile, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	topLevel, err := runGit(gitRoot, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	topLevel = strings.TrimSpace(topLevel)
+
+	if _, err := runGit(gitRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+
+	// Renames are listed as a deletion and an addition, so the old path of a renamed unit is dropped too
+	diff, err := runGit(gitRoot, "diff", "--name-status", "--no-renames", "-z", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(gitRoot, "ls-files", "--others", "--exclude-standard", "--full-name", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	// Git resolves symlinks in the paths it prints, so the git root is compared the same way
+	resolvedGitRoot, err := filepath.EvalSymlinks(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	relativePath := func(path string) (string, error) {
+		relative, err := filepath.Rel(resolvedGitRoot, filepath.Join(topLevel, filepath.FromSlash(path)))
+		if err != nil {
+			return "", err
+		}
+		return filepath.ToSlash(relative), nil
+	}
+
+	files := []changedFile{}
+	fields := strings.Split(strings.TrimSuffix(diff, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		path, err := relativePath(fields[i+1])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, changedFile{path: path, deleted: fields[i] == "D"})
+	}
+	for _, untrackedFile := range strings.Split(untracked, "\x00") {
+		if untrackedFile == "" {
+			continue
+		}
+		path, err := relativePath(untrackedFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, changedFile{path: path})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	return files, nil
+}
+
+// Runs git in `dir`, returning what it printed
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), message)
+	}
+
+	return stdout.String(), nil
+}
+
+// Picks the units whose projects have to be regenerated because of the files changed since `opts.ChangedSince`: the
+// units of the projects whose `when_modified` patterns match a changed file, which cover everything the projects
+// were generated from, and the units without a project whose dir holds a changed file. The projects of the picked
+// units, and of the deleted ones, are dropped from `config`, so only the regenerated ones take their place
+func (g *generator) planIncrementalGeneration(log log.Logger, config *AtlantisConfig, terragruntFiles []string) (*incrementalGeneration, error) {
+	changed, err := gitChangedFiles(g.gitRoot, g.opts.ChangedSince)
+	if err != nil {
+		return nil, err
+	}
+	changedPaths := []string{}
+	for _, file := range changed {
+		changedPaths = append(changedPaths, file.path)
+	}
+
+	unitsByDir := map[string][]string{}
+	for _, terragruntFile := range terragruntFiles {
+		unitsByDir[g.relativeDir(terragruntFile)] = append(unitsByDir[g.relativeDir(terragruntFile)], terragruntFile)
+	}
+
+	plan := &incrementalGeneration{reasons: map[string]string{}, removed: map[string]string{}}
+	projectDirs := map[string]bool{}
+	for _, project := range config.Projects {
+		if isUnmanaged(project) {
+			continue
+		}
+		projectDirs[project.Dir] = true
+
+		file, err := project.MatchingFile(changedPaths)
+		if err != nil {
+			return nil, err
+		}
+		if file == "" {
+			continue
+		}
+
+		reason := fmt.Sprintf("%s changed", file)
+		units := unitsByDir[project.Dir]
+		if len(units) == 0 {
+			plan.removed[project.Dir] = fmt.Sprintf("%s changed and no terragrunt config is left in %s", file, project.Dir)
+		}
+		for _, unit := range units {
+			if _, ok := plan.reasons[unit]; !ok {
+				plan.reasons[unit] = reason
+			}
+		}
+	}
+
+	// Units without a project are either new, or were skipped. Either way their own dir is all that's known of them
+	for dir, units := range unitsByDir {
+		if projectDirs[dir] {
+			continue
+		}
+		for _, file := range changed {
+			if file.deleted || filepath.ToSlash(filepath.Dir(file.path)) != dir {
+				continue
+			}
+			for _, unit := range units {
+				reason := fmt.Sprintf("%s changed", file.path)
+				if filepath.ToSlash(g.relativePath(unit)) == file.path {
+					reason = fmt.Sprintf("%s is new", file.path)
+				}
+				if _, ok := plan.reasons[unit]; !ok {
+					plan.reasons[unit] = reason
+				}
+			}
+		}
+	}
+
+	regeneratedDirs := map[string]bool{}
+	for unit := range plan.reasons {
+		regeneratedDirs[g.relativeDir(unit)] = true
+	}
+	projects := []AtlantisProject{}
+	for _, project := range config.Projects {
+		if isUnmanaged(project) || (!regeneratedDirs[project.Dir] && plan.removed[project.Dir] == "") {
+			projects = append(projects, project)
+		}
+	}
+	config.Projects = projects
+
+	log.Infof("%d files changed since %s, regenerating %d units", len(changed), g.opts.ChangedSince, len(plan.reasons))
+	removedDirs := []string{}
+	for dir := range plan.removed {
+		removedDirs = append(removedDirs, dir)
+	}
+	sort.Strings(removedDirs)
+	for _, dir := range removedDirs {
+		log.Infof("Removed the projects of %s: %s", dir, plan.removed[dir])
+	}
+
+	return plan, nil
+}
+
+// Whether the unit at `path` is regenerated. Without `opts.ChangedSince`, every unit is
+func (plan *incrementalGeneration) includes(path string) bool {
+	if plan == nil {
+		return true
+	}
+
+	_, ok := plan.reasons[path]
+	return ok
+}
+
+// Reports why the projects of the unit at `path` were regenerated
+func (plan *incrementalGeneration) report(log log.Logger, path string, projects []*AtlantisProject) {
+	if plan == nil {
+		return
+	}
+
+	if len(projects) == 0 {
+		log.Infof("Regenerated %s without any project, as %s", path, plan.reasons[path])
+	}
+	for _, project := range projects {
+		name := project.Dir
+		if project.Name != "" {
+			name = project.Name
+		}
+		log.Infof("Regenerated project %s, as %s", name, plan.reasons[path])
+	}
+}
+
+// The dir of the config file at `path`, relative to the git root like project dirs are
+func (g *generator) relativeDir(path string) string {
+	dir := filepath.Dir(g.relativePath(path))
+	return filepath.ToSlash(dir)
+}
+
+// The path of `path` relative to the git root
+func (g *generator) relativePath(path string) string {
+	relativePath, err := filepath.Rel(g.gitRoot, path)
+	if err != nil {
+		return path
+	}
+
+	return relativePath
+}
+
+// Checks the options an incremental generation can't be combined with
+func validateChangedSince(opts Options) error {
+	if opts.ChangedSince == "" {
+		return nil
+	}
+	if opts.OutputPath == "" {
+		return errors.New("incremental generation needs an output path, where the config of an earlier run is merged into")
+	}
+	if len(opts.ProjectHclFiles) > 0 {
+		return errors.New("incremental generation can't be combined with project hcl files")
+	}
+	if opts.ExpandStacks {
+		return errors.New("incremental generation can't be combined with expanded stacks")
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+// Represents an entire config file
+type AtlantisConfig struct {
+	// Version of the config syntax
+	Version int `json:"version"`
+
+	// If Atlantis should merge after finishing `atlantis apply`
+	AutoMerge bool `json:"automerge"`
+
+	// If Atlantis should allow plans to occur in parallel
+	ParallelPlan bool `json:"parallel_plan"`
+
+	// If Atlantis should allow applies to occur in parallel
+	ParallelApply bool `json:"parallel_apply"`
+
+	// If Atlantis should stop planning or applying later execution order groups once one fails
+	AbortOnExecutionOrderFail *bool `json:"abort_on_execution_order_fail,omitempty"`
+
+	// If the source branch should be deleted once the PR is merged
+	DeleteSourceBranchOnMerge *bool `json:"delete_source_branch_on_merge,omitempty"`
+
+	// Prefixes `atlantis plan/apply -p` regexps must start with
+	AllowedRegexpPrefixes []string `json:"allowed_regexp_prefixes,omitempty"`
+
+	// If Atlantis should discover projects beyond the ones listed in this config
+	Autodiscover *AutodiscoverConfig `json:"autodiscover,omitempty"`
+
+	// The project settings
+	Projects []AtlantisProject `json:"projects,omitempty"`
+
+	// Workflows, which are not managed by this library other than
+	// the fact that this library preserves any existing workflows
+	Workflows interface{} `json:"workflows,omitempty"`
+
+	// Keys this library does not model, preserved from an old config. They are output verbatim
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Outputs the modelled fields of a config together with its extra keys
+func (config AtlantisConfig) MarshalJSON() ([]byte, error) {
+	type plainConfig AtlantisConfig
+	return marshalWithExtra(plainConfig(config), config.Extra)
+}
+
+// Reads a config, keeping the keys AtlantisConfig doesn't model in `Extra`
+func (config *AtlantisConfig) UnmarshalJSON(bytes []byte) error {
+	type plainConfig AtlantisConfig
+	plain := plainConfig{}
+	err := json.Unmarshal(bytes, &plain)
+	if err != nil {
+		return err
+	}
+
+	plain.Extra, err = unknownKeys(bytes, AtlantisConfig{})
+	if err != nil {
+		return err
+	}
+
+	*config = AtlantisConfig(plain)
+	return nil
+}
+
+// Represents an Atlantis Project directory
+type AtlantisProject struct {
+	// The directory with the terragrunt.hcl file
+	Dir string `json:"dir"`
+
+	// Define workflow name
+	Workflow string `json:"workflow,omitempty"`
+
+	// Define workspace name
+	Workspace string `json:"workspace,omitempty"`
+
+	// Define project name
+	Name string `json:"name,omitempty"`
+
+	// Autoplan settings for which plans affect other plans
+	Autoplan AutoplanConfig `json:"autoplan"`
+
+	// The terraform version to use for this project
+	TerraformVersion string `json:"terraform_version,omitempty"`
+
+	// We only want to output `apply_requirements` if explicitly stated in a local value
+	ApplyRequirements *[]string `json:"apply_requirements,omitempty"`
+
+	// Requirements for `atlantis plan`, only output if explicitly stated in a local value
+	PlanRequirements *[]string `json:"plan_requirements,omitempty"`
+
+	// Requirements for `atlantis import`, only output if explicitly stated in a local value
+	ImportRequirements *[]string `json:"import_requirements,omitempty"`
+
+	// Regex of the base branches this project is planned for
+	Branch string `json:"branch,omitempty"`
+
+	// When the repo lock of this project is acquired
+	RepoLocks *RepoLocksConfig `json:"repo_locks,omitempty"`
+
+	// If custom policy checks are enabled for this project
+	CustomPolicyCheck *bool `json:"custom_policy_check,omitempty"`
+
+	// The commands whose PR comments are silenced
+	SilencePRComments []string `json:"silence_pr_comments,omitempty"`
+
+	// The distribution of terraform to use, e.g. terraform or opentofu
+	TerraformDistribution string `json:"terraform_distribution,omitempty"`
+
+	// If the source branch should be deleted once the PR is merged
+	DeleteSourceBranchOnMerge *bool `json:"delete_source_branch_on_merge,omitempty"`
+
+	// Atlantis use ExecutionOrderGroup for sort projects before applying/planning
+	ExecutionOrderGroup *int `json:"execution_order_group,omitempty"`
+
+	// Atlantis uses DependsOn to define dependencies between projects
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Keys this library does not model, e.g. set through `atlantis_project_overrides`. They are output verbatim
+	Extra map[string]interface{} `json:"-"`
+
+	// Name of the matrix or workspace variant the project was created for, empty outside of variants
+	variant string
+
+	// Absolute paths of the config files the project was created for. Depending on one of them is depending on
+	// the project
+	configFiles []string
+
+	// Absolute paths of the files and globs the project depends on, as resolved while parsing
+	dependencies []string
+
+	// Set for the projects of an old config marked with a `# managed: false` comment, which are written by hand
+	unmanaged bool
+}
+
+// Outputs the modelled fields of a project together with its extra keys
+func (project AtlantisProject) MarshalJSON() ([]byte, error) {
+	type plainProject AtlantisProject
+	return marshalWithExtra(plainProject(project), project.Extra)
+}
+
+// Reads a project, keeping the keys AtlantisProject doesn't model in `Extra`
+func (project *AtlantisProject) UnmarshalJSON(bytes []byte) error {
+	type plainProject AtlantisProject
+	plain := plainProject{}
+	err := json.Unmarshal(bytes, &plain)
+	if err != nil {
+		return err
+	}
+
+	plain.Extra, err = unknownKeys(bytes, AtlantisProject{})
+	if err != nil {
+		return err
+	}
+
+	*project = AtlantisProject(plain)
+	return nil
+}
+
+// Settings for the projects Atlantis discovers on its own
+type AutodiscoverConfig struct {
+	// One of auto, enabled or disabled
+	Mode string `json:"mode"`
+
+	// Globs of the paths Atlantis never discovers projects in
+	IgnorePaths []string `json:"ignore_paths,omitempty"`
+}
+
+// Repo lock settings of a project
+type RepoLocksConfig struct {
+	// One of on_plan, on_apply or disabled
+	Mode string `json:"mode"`
+}
+
+// Autoplan settings for which plans affect other plans
+type AutoplanConfig struct {
+	// Relative paths from this modules directory to modules it depends on
+	WhenModified []string `json:"when_modified"`
+
+	// If autoplan should be enabled for this dir
+	Enabled bool `json:"enabled"`
+}
+
+// The JSON keys of all fields the struct `modelled` has
+func jsonKeys(modelled interface{}) map[string]bool {
+	keys := map[string]bool{}
+
+	modelledType := reflect.TypeOf(modelled)
+	for i := 0; i < modelledType.NumField(); i++ {
+		name := strings.Split(modelledType.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+
+	return keys
+}
+
+// Returns the keys of the JSON object in `bytes` that the struct `modelled` has no field for, or nil if there
+// are none
+func unknownKeys(bytes []byte, modelled interface{}) (map[string]interface{}, error) {
+	generic := map[string]interface{}{}
+	err := json.Unmarshal(bytes, &generic)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown map[string]interface{}
+	knownKeys := jsonKeys(modelled)
+	for key, value := range generic {
+		if knownKeys[key] {
+			continue
+		}
+		if unknown == nil {
+			unknown = map[string]interface{}{}
+		}
+		unknown[key] = value
+	}
+
+	return unknown, nil
+}
+
+// Marshals `plain`, a struct without a custom MarshalJSON, and adds the keys of `extra` it doesn't output itself
+func marshalWithExtra(plain interface{}, extra map[string]interface{}) ([]byte, error) {
+	if len(extra) == 0 {
+		return json.Marshal(plain)
+	}
+
+	generic, err := toGenericMap(plain)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := generic[key]; !ok {
+			generic[key] = value
+		}
+	}
+
+	return json.Marshal(generic)
+}
+
+// Carries the extra keys of a project from an old config over to the project regenerated in its place, and warns
+// about every key of the old project the generated one sets to another value or drops. The generated value wins
+func preserveProjectKeys(log log.Logger, old AtlantisProject, generated *AtlantisProject) error {
+	oldProject, err := toGenericMap(old)
+	if err != nil {
+		return err
+	}
+	generatedProject, err := toGenericMap(*generated)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{}
+	for key := range oldProject {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		generatedValue, ok := generatedProject[key]
+		if !ok {
+			if extraValue, isExtra := old.Extra[key]; isExtra {
+				if generated.Extra == nil {
+					generated.Extra = map[string]interface{}{}
+				}
+				generated.Extra[key] = extraValue
+				continue
+			}
+			log.Warnf("Project %s: %q of the old config is dropped, as the generated project doesn't set it", generated.Dir, key)
+			continue
+		}
+		if !reflect.DeepEqual(generatedValue, oldProject[key]) {
+			log.Warnf("Project %s: the generated value of %q replaces the one preserved from the old config", generated.Dir, key)
+		}
+	}
+
+	return nil
+}
+
+// Checks if an output file already exists. If it does, it reads it
+// in to preserve some parts of the old config
+func ReadOldConfig(log log.Logger, outputPath string) (*AtlantisConfig, error) {
+	// The old file not existing is not an error, as it should not exist on the very first run
+	bytes, err := os.ReadFile(outputPath)
+	if err != nil {
+		log.Info("Could not find an old config file. Starting from scratch")
+		return nil, nil
+	}
+
+	// The old file being malformed is an actual error
+	config := AtlantisConfig{}
+	err = yaml.Unmarshal(bytes, &config)
+	if err != nil {
+		return nil, err
+	}
+	err = markUnmanagedProjects(bytes, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Outputs a config as YAML, keeping the `# managed: false` comments of the projects written by hand
+func MarshalConfig(config *AtlantisConfig) ([]byte, error) {
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return commentUnmanagedProjects(bytes, config), nil
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+// DependencyCycleError is returned when modules depend on each other in a cycle, as Atlantis can't order them
+type DependencyCycleError struct {
+	// The edges of the cycle in order, between paths relative to the git root. The last edge leads back to where
+	// the first one starts. The kind is empty when the block introducing an edge isn't known
+	Cycle []Edge
+}
+
+func (err *DependencyCycleError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString("dependency cycle: ")
+	builder.WriteString(err.Cycle[0].From)
+	for _, edge := range err.Cycle {
+		if edge.Kind == "" {
+			fmt.Fprintf(&builder, " -> %s", edge.To)
+		} else {
+			fmt.Fprintf(&builder, " -(%s)-> %s", edge.Kind, edge.To)
+		}
+	}
+
+	return builder.String()
+}
+
+// One step of a cycle, from the config file at `from` to the one at `to`, because `from` depends on `target`. The
+// target is a file in the dir of `to`, or `to` itself. All paths are absolute
+type cycleLink struct {
+	from   string
+	to     string
+	target string
+}
+
+// Finds the kind of the recorded edge from `from` to `target`. Cascaded dependencies weren't recorded from the module
+// depending on them, so then the kind of any edge to `target` is used
+func (g *generator) edgeKind(from string, target string) EdgeKind {
+	g.graph.mtx.Lock()
+	defer g.graph.mtx.Unlock()
+
+	kinds := []string{}
+	for edge := range g.graph.edges {
+		if edge.To != target {
+			continue
+		}
+		if edge.From == from {
+			return edge.Kind
+		}
+		kinds = append(kinds, string(edge.Kind))
+	}
+	if len(kinds) == 0 {
+		return ""
+	}
+	sort.Strings(kinds)
+
+	return EdgeKind(kinds[0])
+}
+
+// Builds the error for a cycle, starting from its alphabetically first file so the same cycle is always reported
+// the same way
+func (g *generator) newDependencyCycleError(links []cycleLink) *DependencyCycleError {
+	edges := []Edge{}
+	first := 0
+	for i, link := range links {
+		edges = append(edges, Edge{
+			From: g.nodeID(link.from),
+			To:   g.nodeID(link.to),
+			Kind: g.edgeKind(filepath.Clean(link.from), filepath.Clean(link.target)),
+		})
+		if edges[i].From < edges[first].From {
+			first = i
+		}
+	}
+
+	return &DependencyCycleError{Cycle: append(edges[first:], edges[:first]...)}
+}
+
+// Builds the error for a cycle of config files, where each depends on the next one and the last one is the first
+func (g *generator) newFileCycleError(paths []string) *DependencyCycleError {
+	links := []cycleLink{}
+	for i := 0; i+1 < len(paths); i++ {
+		links = append(links, cycleLink{from: paths[i], to: paths[i+1], target: paths[i+1]})
+	}
+
+	return g.newDependencyCycleError(links)
+}
+
+// Warns about a cycle broken with `opts.AllowCycles`, once for every dependency ignored to break it
+func (g *generator) reportCycle(log log.Logger, err *DependencyCycleError, ignoredFrom string, ignoredTo string) {
+	message := fmt.Sprintf("%s. Ignoring the dependency of %s on %s to break it", err, g.nodeID(ignoredFrom), g.nodeID(ignoredTo))
+	if _, reported := g.reportedCycles.LoadOrStore(message, true); !reported {
+		log.Warn(message)
+	}
+}
+
+// Looks for cycles between projects with a depth first search, going through the projects and their dependencies in
+// order. Fails on the first cycle found, unless `opts.AllowCycles` is set. Then the dependency closing each cycle is
+// removed from `dependencies` instead, so the same cycles are always broken the same way
+func (g *generator) breakProjectCycles(log log.Logger, projects []AtlantisProject, dependencies map[*AtlantisProject][]projectDependency) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := map[*AtlantisProject]int{}
+
+	// The projects being visited, and the dependency followed from each to the next
+	stack := []*AtlantisProject{}
+	followed := []projectDependency{}
+
+	var visit func(project *AtlantisProject) error
+	visit = func(project *AtlantisProject) error {
+		states[project] = visiting
+		stack = append(stack, project)
+
+		kept := []projectDependency{}
+		for _, dependency := range dependencies[project] {
+			switch states[dependency.project] {
+			case visiting:
+				start := 0
+				for stack[start] != dependency.project {
+					start++
+				}
+
+				links := []cycleLink{}
+				for i, dependency := range append(slices.Clone(followed[start:]), dependency) {
+					from := stack[start+i]
+					links = append(links, cycleLink{
+						from:   g.projectConfigFiles(from)[0],
+						to:     g.projectConfigFiles(dependency.project)[0],
+						target: dependency.target,
+					})
+				}
+				err := g.newDependencyCycleError(links)
+				if !g.opts.AllowCycles {
+					return err
+				}
+				g.reportCycle(log, err, links[len(links)-1].from, links[len(links)-1].to)
+				continue
+			case unvisited:
+				followed = append(followed, dependency)
+				err := visit(dependency.project)
+				followed = followed[:len(followed)-1]
+				if err != nil {
+					return err
+				}
+			}
+			kept = append(kept, dependency)
+		}
+		dependencies[project] = kept
+
+		stack = stack[:len(stack)-1]
+		states[project] = visited
+		return nil
+	}
+
+	for i := range projects {
+		if states[&projects[i]] == unvisited {
+			err := visit(&projects[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
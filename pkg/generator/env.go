@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Splits a variable in the format `KEY=VALUE`
+func parseEnvVariable(raw string) (string, string, error) {
+	key, value, found := strings.Cut(raw, "=")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", "", fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", raw)
+	}
+
+	return key, value, nil
+}
+
+// Reads the variables of an env file. Each line is a `KEY=VALUE` pair, optionally prefixed by `export`, and values
+// may be wrapped in quotes. Blank lines and lines starting with `#` are skipped
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, err := parseEnvVariable(strings.TrimPrefix(line, "export "))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value, err = strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid quoted value for %s: %w", path, lineNumber, key, err)
+			}
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// Builds the environment `get_env` sees while parsing. It starts from the process environment, or from nothing with
+// `opts.CleanEnv`, then the env files are applied in order, then the variables of `opts.Env`
+func buildEnv(opts Options) (map[string]string, error) {
+	env := map[string]string{}
+	if !opts.CleanEnv {
+		for _, variable := range os.Environ() {
+			key, value, _ := strings.Cut(variable, "=")
+			env[key] = value
+		}
+	}
+
+	for _, envFile := range opts.EnvFiles {
+		fileEnv, err := readEnvFile(envFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileEnv {
+			env[key] = value
+		}
+	}
+
+	for _, variable := range opts.Env {
+		key, value, err := parseEnvVariable(variable)
+		if err != nil {
+			return nil, err
+		}
+		env[key] = value
+	}
+
+	return env, nil
+}
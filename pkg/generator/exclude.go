@@ -0,0 +1,30 @@
+package generator
+
+import (
+	"github.com/gruntwork-io/terragrunt/config"
+)
+
+// The terragrunt actions Atlantis runs. A unit excluded from any of them can't be a project
+var atlantisActions = []string{"plan", "apply"}
+
+// Whether the `exclude` block of a unit keeps Atlantis from running it
+func isExcludedFromAtlantis(exclude *config.ExcludeConfig) bool {
+	if exclude == nil || !exclude.If {
+		return false
+	}
+
+	for _, action := range atlantisActions {
+		if exclude.IsActionListed(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether `getDependencies` skipped the unit at `path` because of its `exclude` block
+func (g *generator) isExcluded(path string) bool {
+	cachedResult, ok := g.dependenciesCache.get(path)
+
+	return ok && cachedResult.excluded
+}
@@ -0,0 +1,1181 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/hashicorp/go-getter"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
+
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A single generation run. Everything cached while parsing lives here, so runs never share state
+type generator struct {
+	opts Options
+
+	// Absolute path of `opts.GitRoot`, always with a trailing separator
+	gitRoot string
+
+	// The terragrunt configs parsed so far, each parsed once
+	units *parsedUnitCache
+
+	requestGroup      singleflight.Group
+	dependenciesCache *getDependenciesCache
+
+	// The dependencies of each module including the cascaded ones, when no cycle had to be broken to find them
+	cascadedDependenciesCache *getDependenciesCache
+
+	// Records the dependency graph while generating, which dependency cycles are reported from
+	graph *graphRecorder
+
+	// The dependency cycles already warned about with `opts.AllowCycles`, so each is only warned about once
+	reportedCycles *sync.Map
+
+	// The parsed `opts.Rules`, in order
+	rules []*rule
+
+	// The environment `get_env` sees while parsing
+	env map[string]string
+
+	// Stubs replacing the unsafe functions while parsing. Empty unless `opts.SafeMode` is set
+	stubbedFunctions map[string]function.Function
+
+	// The units cached in `opts.CacheDir` by earlier runs. Nil without a cache dir
+	cache *parseCache
+
+	// Positions of the `file` calls in terraform modules already warned about, so each is only warned about once
+	unresolvedFileReferences *sync.Map
+
+	// The variant this generator evaluates units as. Nil outside of a matrix or workspace variant
+	variant *variant
+
+	// One generator per variant of `opts.Matrix`. Empty without a matrix
+	matrixGenerators []*generator
+
+	// The generators of the workspaces set through `atlantis_workspaces`
+	workspaceGenerators *workspaceGenerators
+}
+
+// Generate builds the Atlantis config for the Terragrunt modules below `opts.GitRoot`.
+// Each call keeps its own caches, so several generations can safely run in one process.
+// Generate never writes the config, see `opts.OutputPath` for how an existing file is used.
+func Generate(ctx context.Context, opts Options) (*AtlantisConfig, error) {
+	g, logger, err := newGenerator(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.generate(ctx, logger)
+}
+
+// Sets up a generator for a single run, resolving the defaults of `opts`
+func newGenerator(ctx context.Context, opts Options) (*generator, log.Logger, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.LoggerFromContext(ctx)
+	}
+	if logger == nil {
+		logger = log.New(
+			log.WithOutput(os.Stderr),
+			log.WithLevel(options.DefaultLogLevel),
+			log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())),
+		)
+	}
+
+	// Ensure the gitRoot has a trailing slash and is an absolute path
+	absoluteGitRoot, err := filepath.Abs(opts.GitRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A semaphore without any weight would block forever
+	if opts.NumExecutors < 1 {
+		opts.NumExecutors = 1
+	}
+
+	rules, err := parseRules(opts.Rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Computed groups follow the dependencies, which a group set by hand would break
+	for _, rule := range rules {
+		if rule.executionOrderGroup != nil && opts.ExecutionOrderGroups {
+			return nil, nil, fmt.Errorf("rule %q sets execution_order_group, which can't be combined with computed execution order groups", rule.raw)
+		}
+	}
+
+	fixtures, err := parseSafeModeFixtures(opts.SafeModeFixtures)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fixtures) > 0 && !opts.SafeMode {
+		return nil, nil, errors.New("safe mode fixtures have no effect unless safe mode is enabled")
+	}
+
+	env, err := buildEnv(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateChangedSince(opts); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.PreserveMatch == "" {
+		opts.PreserveMatch = preserveMatchDirWorkspaceName
+	}
+	if err := validatePreserveMatch(opts.PreserveMatch); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.PrunePreserved && !opts.PreserveProjects && opts.ChangedSince == "" {
+		return nil, nil, errors.New("pruning preserved projects has no effect unless projects are preserved")
+	}
+	if opts.PruneDryRun && !opts.PrunePreserved {
+		return nil, nil, errors.New("a prune dry run has no effect unless preserved projects are pruned")
+	}
+
+	if opts.CacheStats && opts.CacheDir == "" {
+		return nil, nil, errors.New("cache stats have no effect unless a cache dir is set")
+	}
+	var cache *parseCache
+	if opts.CacheDir != "" {
+		cache, err = newParseCache(opts.CacheDir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matrix, err := parseMatrix(opts.Matrix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g := &generator{
+		opts:                      opts,
+		gitRoot:                   absoluteGitRoot + string(filepath.Separator),
+		units:                     newParsedUnitCache(),
+		dependenciesCache:         newGetDependenciesCache(),
+		cascadedDependenciesCache: newGetDependenciesCache(),
+		graph:                     newGraphRecorder(),
+		reportedCycles:            &sync.Map{},
+		rules:                     rules,
+		env:                       env,
+		cache:                     cache,
+		unresolvedFileReferences:  &sync.Map{},
+		workspaceGenerators:       &workspaceGenerators{generators: map[string]*generator{}},
+	}
+	if opts.SafeMode {
+		g.stubbedFunctions = safeModeFunctions(fixtures)
+	}
+	for _, matrixVariant := range matrixVariants(matrix) {
+		g.matrixGenerators = append(g.matrixGenerators, g.forVariant(matrixVariant))
+	}
+
+	return g, logger, nil
+}
+
+// Creates the context for parsing the config at `path`, with the unsafe functions stubbed in safe mode
+func (g *generator) newParsingContext(ctx context.Context, log log.Logger, path string) (*TerragruntParsingContext, error) {
+	parsingContext, err := NewParsingContextWithConfigPath(ctx, log, path, g.env)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsingContext.WithStubbedFunctions(g.stubbedFunctions), nil
+}
+
+// Terragrunt imports can be relative or absolute
+// This makes relative paths absolute
+func (g *generator) makePathAbsolute(path string, parentPath string) string {
+	if strings.HasPrefix(path, filepath.ToSlash(g.gitRoot)) {
+		return path
+	}
+
+	parentDir := filepath.Dir(parentPath)
+	return filepath.Join(parentDir, path)
+}
+
+// Set up a cache for the getDependencies function
+type getDependenciesOutput struct {
+	dependencies []string
+
+	// Files of the local terraform module in the dir of the unit, which aren't followed when cascading
+	moduleFiles []string
+
+	err error
+
+	// Set when the unit is skipped because its `exclude` block applies to plan or apply
+	excluded bool
+
+	// The configs the unit includes, which were evaluated along with it and so aren't followed when cascading
+	includes []string
+
+	// The locals of the unit, merged over the locals of the configs it includes
+	locals ResolvedLocals
+
+	// The edges of the dependency graph from the unit
+	edges []Edge
+
+	// The dirs of the terraform modules scanned for module files, whose contents the dependencies were found from,
+	// and the dirs whose existence decided which files a module reads
+	moduleDirs []string
+}
+
+type getDependenciesCache struct {
+	mtx  sync.RWMutex
+	data map[string]getDependenciesOutput
+}
+
+func newGetDependenciesCache() *getDependenciesCache {
+	return &getDependenciesCache{data: map[string]getDependenciesOutput{}}
+}
+
+func (m *getDependenciesCache) set(k string, v getDependenciesOutput) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.data[k] = v
+}
+
+func (m *getDependenciesCache) get(k string) (getDependenciesOutput, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	v, ok := m.data[k]
+	return v, ok
+}
+
+func uniqueStrings(str []string) []string {
+	keys := make(map[string]bool)
+	list := []string{}
+	for _, entry := range str {
+		if _, value := keys[entry]; !value {
+			keys[entry] = true
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func lookupProjectHcl(m map[string][]string, value string) (key string) {
+	for k, values := range m {
+		for _, val := range values {
+			if val == value {
+				key = k
+				return
+			}
+		}
+	}
+	return key
+}
+
+// sliceUnion takes two slices of strings and produces a union of them, containing only unique values
+func sliceUnion(a, b []string) []string {
+	m := make(map[string]bool)
+
+	for _, item := range a {
+		m[item] = true
+	}
+
+	for _, item := range b {
+		if _, ok := m[item]; !ok {
+			a = append(a, item)
+		}
+	}
+	return a
+}
+
+// Parses the terragrunt config at `path` to find all modules it depends on, following the dependencies of those
+// modules too with `opts.CascadeDependencies`. Returns nil when the module should be skipped
+func (g *generator) getDependencies(ctx context.Context, log log.Logger, path string) ([]string, error) {
+	dependencies, _, err := g.cascadeDependencies(ctx, log, path, []string{filepath.Clean(path)})
+
+	return dependencies, err
+}
+
+// Finds the dependencies of `path` and, with `opts.CascadeDependencies`, those of its dependencies. `chain` holds the
+// modules being followed, starting from the one a project is created for and ending with `path`, so a dependency
+// leading back into it closes a cycle. Also returns whether a cycle was broken, as the result then depends on where
+// the chain started and can't be cached
+func (g *generator) cascadeDependencies(ctx context.Context, log log.Logger, path string, chain []string) ([]string, bool, error) {
+	if cachedResult, ok := g.cascadedDependenciesCache.get(path); ok {
+		return cachedResult.dependencies, false, cachedResult.err
+	}
+
+	direct, err := g.getDirectDependencies(ctx, log, path)
+	if err != nil || direct.dependencies == nil {
+		return nil, false, err
+	}
+
+	// Recurse to find dependencies of all dependencies
+	cascadedDeps := []string{}
+	brokeCycle := false
+	for _, dep := range direct.dependencies {
+		cascadedDeps = append(cascadedDeps, dep)
+
+		// The "cascading" feature is protected by a flag
+		if !g.opts.CascadeDependencies || slices.Contains(direct.includes, dep) {
+			continue
+		}
+
+		depPath := dep
+		if i := slices.Index(chain, filepath.Clean(depPath)); i >= 0 {
+			err := g.newFileCycleError(append(slices.Clone(chain[i:]), filepath.Clean(depPath)))
+			if !g.opts.AllowCycles {
+				return nil, false, err
+			}
+			g.reportCycle(log, err, path, depPath)
+			brokeCycle = true
+			continue
+		}
+
+		childDeps, childBrokeCycle, err := g.cascadeDependencies(ctx, log, depPath, append(slices.Clone(chain), filepath.Clean(depPath)))
+		if err != nil {
+			var cycleErr *DependencyCycleError
+			if errors.As(err, &cycleErr) {
+				return nil, false, err
+			}
+			continue
+		}
+		brokeCycle = brokeCycle || childBrokeCycle
+
+		for _, childDep := range childDeps {
+			// If `childDep` is a relative path, it will be relative to `childDep`, as it is from the nested
+			// `getDependencies` call on the top level module's dependencies. So here we update any relative
+			// path to be from the top level module instead.
+			childDepAbsPath := childDep
+			if !filepath.IsAbs(childDep) {
+				childDepAbsPath, err = filepath.Abs(filepath.Join(depPath, "..", childDep))
+				if err != nil {
+					return nil, false, err
+				}
+			}
+			childDepAbsPath = filepath.ToSlash(childDepAbsPath)
+
+			// Ensure we are not adding a duplicate dependency
+			alreadyExists := false
+			for _, dep := range cascadedDeps {
+				if dep == childDepAbsPath {
+					alreadyExists = true
+					break
+				}
+			}
+			if !alreadyExists {
+				cascadedDeps = append(cascadedDeps, childDepAbsPath)
+			}
+		}
+	}
+
+	cascadedDeps = append(cascadedDeps, direct.moduleFiles...)
+	if !brokeCycle {
+		g.cascadedDependenciesCache.set(path, getDependenciesOutput{dependencies: cascadedDeps})
+	}
+
+	return cascadedDeps, brokeCycle, nil
+}
+
+// Parses the terragrunt config at `path` to find the files it depends on itself, without following any of them.
+// Nil dependencies are a sign that the module should be skipped
+func (g *generator) getDirectDependencies(ctx context.Context, log log.Logger, path string) (getDependenciesOutput, error) {
+	res, err, _ := g.requestGroup.Do(path, func() (interface{}, error) {
+		// Check if this path has already been computed
+		cachedResult, ok := g.dependenciesCache.get(path)
+		if ok {
+			return cachedResult, cachedResult.err
+		}
+
+		output, err := g.loadDirectDependencies(ctx, log, path)
+		if err != nil {
+			g.dependenciesCache.set(path, getDependenciesOutput{err: err})
+			return getDependenciesOutput{}, err
+		}
+		g.addEdges(output.edges)
+		g.dependenciesCache.set(path, output)
+		return output, nil
+	})
+
+	if res != nil {
+		return res.(getDependenciesOutput), err
+	} else {
+		return getDependenciesOutput{}, err
+	}
+}
+
+// Resolves the direct dependencies of the unit at `path` from the unit parsed by `parseUnit`
+func (g *generator) resolveDirectDependencies(ctx context.Context, log log.Logger, path string) (getDependenciesOutput, error) {
+	unit, err := g.parseUnit(ctx, log, path)
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+	// return nils to indicate we should skip this project
+	if unit.isParent && g.opts.IgnoreParentTerragrunt {
+		return getDependenciesOutput{locals: unit.locals}, nil
+	}
+
+	edges := []Edge{}
+	dependencies := []string{}
+	for _, includeDep := range unit.includes {
+		dependencies = append(dependencies, includeDep.Path)
+		edges = append(edges, g.newEdges(path, EdgeInclude, includeDep.Path)...)
+	}
+
+	// Units excluded from plan or apply are skipped, just like with `atlantis_skip`
+	terragruntConfig := unit.config
+	if isExcludedFromAtlantis(terragruntConfig.Exclude) {
+		log.Infof("Skipping %s, as its exclude block applies to %s", path, strings.Join(atlantisActions, " or "))
+		return getDependenciesOutput{excluded: true, edges: edges}, nil
+	}
+
+	// Get deps from locals
+	if unit.locals.ExtraAtlantisDependencies != nil {
+		dependencies = sliceUnion(dependencies, unit.locals.ExtraAtlantisDependencies)
+		edges = append(edges, g.newEdges(path, EdgeExtra, unit.locals.ExtraAtlantisDependencies...)...)
+	}
+
+	// Get deps from the files read while parsing, through functions like `read_terragrunt_config` or `file`
+	readFiles := uniqueStrings(slices.Clone(unit.filesRead))
+	sort.Strings(readFiles)
+	dependencies = sliceUnion(dependencies, readFiles)
+	edges = append(edges, g.newEdges(path, EdgeRead, readFiles...)...)
+
+	// Get deps from `dependencies` and `dependency` blocks
+	if terragruntConfig.Dependencies != nil && !g.opts.IgnoreDependencyBlocks {
+		for _, parsedPaths := range terragruntConfig.Dependencies.Paths {
+			dependencies = append(dependencies, filepath.Join(parsedPaths, "terragrunt.hcl"))
+			edges = append(edges, g.newEdges(path, EdgeDependency, filepath.Join(parsedPaths, "terragrunt.hcl"))...)
+		}
+	}
+
+	// The dirs of the terraform modules scanned for the files they depend on
+	moduleDirs := []string{}
+
+	// Get deps from the `Source` field of the `Terraform` block
+	if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.Source != nil {
+		source := terragruntConfig.Terraform.Source
+
+		// Use `go-getter` to normalize the source paths
+		parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
+		if err != nil {
+			return getDependenciesOutput{}, err
+		}
+
+		// Check if the path begins with a drive letter, denoting Windows
+		isWindowsPath, err := regexp.MatchString(`^[A-Za-z]:`, parsedSource)
+		if err != nil {
+			return getDependenciesOutput{}, err
+		}
+
+		// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
+		if strings.HasPrefix(parsedSource, "file://") || isWindowsPath {
+			// Remove the prefix so we have a valid filesystem path
+			parsedSource = strings.TrimPrefix(parsedSource, "file://")
+
+			dependencies = append(dependencies, filepath.Join(parsedSource, "*.tf*"))
+
+			ls, probedDirs, err := g.parseTerraformLocalModuleSource(log, parsedSource)
+			if err != nil {
+				return getDependenciesOutput{}, err
+			}
+			sort.Strings(ls)
+
+			dependencies = append(dependencies, ls...)
+			edges = append(edges, g.newEdges(path, EdgeModuleSource, filepath.Join(parsedSource, "*.tf*"))...)
+			edges = append(edges, g.newEdges(path, EdgeModuleSource, ls...)...)
+			moduleDirs = append(moduleDirs, scannedModuleDirs(parsedSource, ls)...)
+			moduleDirs = append(moduleDirs, probedDirs...)
+		}
+	}
+
+	// Get deps from `extra_arguments` fields of the `Terraform` block
+	if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.ExtraArgs != nil {
+		extraArgs := terragruntConfig.Terraform.ExtraArgs
+		for _, arg := range extraArgs {
+			if arg.RequiredVarFiles != nil {
+				dependencies = append(dependencies, *arg.RequiredVarFiles...)
+				edges = append(edges, g.newEdges(path, EdgeVarFile, *arg.RequiredVarFiles...)...)
+			}
+			if arg.OptionalVarFiles != nil {
+				dependencies = append(dependencies, *arg.OptionalVarFiles...)
+				edges = append(edges, g.newEdges(path, EdgeVarFile, *arg.OptionalVarFiles...)...)
+			}
+			if arg.Arguments != nil {
+				for _, cliFlag := range *arg.Arguments {
+					if strings.HasPrefix(cliFlag, "-var-file=") {
+						dependencies = append(dependencies, strings.TrimPrefix(cliFlag, "-var-file="))
+						edges = append(edges, g.newEdges(path, EdgeVarFile, strings.TrimPrefix(cliFlag, "-var-file="))...)
+					}
+				}
+			}
+		}
+	}
+
+	// Filter out and dependencies that are the empty string
+	nonEmptyDeps := []string{}
+	for _, dep := range dependencies {
+		if dep != "" {
+			childDepAbsPath := dep
+			if !filepath.IsAbs(childDepAbsPath) {
+				childDepAbsPath = g.makePathAbsolute(dep, path)
+			}
+			childDepAbsPath = filepath.ToSlash(childDepAbsPath)
+			nonEmptyDeps = append(nonEmptyDeps, childDepAbsPath)
+		}
+	}
+
+	moduleFiles := []string{}
+	if filepath.Base(path) == "terragrunt.hcl" || filepath.Base(path) == "terragrunt.stack.hcl" {
+		dir := filepath.Dir(path)
+
+		ls, probedDirs, err := g.parseTerraformLocalModuleSource(log, dir)
+		if err != nil {
+			return getDependenciesOutput{}, err
+		}
+		sort.Strings(ls)
+
+		moduleFiles = append(moduleFiles, ls...)
+		edges = append(edges, g.newEdges(path, EdgeModuleSource, ls...)...)
+		moduleDirs = append(moduleDirs, scannedModuleDirs(dir, ls)...)
+		moduleDirs = append(moduleDirs, probedDirs...)
+	}
+
+	includes := []string{}
+	for _, includeDep := range unit.includes {
+		includes = append(includes, filepath.ToSlash(g.makePathAbsolute(includeDep.Path, path)))
+	}
+
+	return getDependenciesOutput{
+		dependencies: nonEmptyDeps,
+		moduleFiles:  moduleFiles,
+		includes:     includes,
+		locals:       unit.locals,
+		edges:        edges,
+		moduleDirs:   uniqueStrings(moduleDirs),
+	}, nil
+}
+
+// Builds a project with the defaults set by the options
+func (g *generator) newProject(dir string, whenModified []string) *AtlantisProject {
+	applyRequirements := &g.opts.DefaultApplyRequirements
+	if len(g.opts.DefaultApplyRequirements) == 0 {
+		applyRequirements = nil
+	}
+
+	return &AtlantisProject{
+		Dir:               filepath.ToSlash(dir),
+		Workflow:          g.opts.DefaultWorkflow,
+		TerraformVersion:  g.opts.DefaultTerraformVersion,
+		ApplyRequirements: applyRequirements,
+		Autoplan: AutoplanConfig{
+			Enabled:      g.opts.AutoPlan,
+			WhenModified: uniqueStrings(whenModified),
+		},
+	}
+}
+
+// Sets the project keys configured through locals, which take precedence over options and rules
+func applyProjectLocals(project *AtlantisProject, locals ResolvedLocals) {
+	if locals.AtlantisWorkflow != "" {
+		project.Workflow = locals.AtlantisWorkflow
+	}
+
+	if locals.ApplyRequirements != nil {
+		project.ApplyRequirements = &locals.ApplyRequirements
+	}
+
+	if locals.AutoPlan != nil {
+		project.Autoplan.Enabled = *locals.AutoPlan
+	}
+
+	if locals.TerraformVersion != "" {
+		project.TerraformVersion = locals.TerraformVersion
+	}
+
+	if locals.PlanRequirements != nil {
+		project.PlanRequirements = &locals.PlanRequirements
+	}
+
+	if locals.ImportRequirements != nil {
+		project.ImportRequirements = &locals.ImportRequirements
+	}
+
+	if locals.RepoLocksMode != "" {
+		project.RepoLocks = &RepoLocksConfig{Mode: locals.RepoLocksMode}
+	}
+
+	project.Branch = locals.Branch
+	project.CustomPolicyCheck = locals.CustomPolicyCheck
+	project.SilencePRComments = locals.SilencePRComments
+	project.TerraformDistribution = locals.TerraformDistribution
+	project.DeleteSourceBranchOnMerge = locals.DeleteSourceBranchOnMerge
+}
+
+// The generators evaluating units once per variant of the matrix, or just this generator without a matrix
+func (g *generator) variantGenerators() []*generator {
+	if len(g.matrixGenerators) == 0 {
+		return []*generator{g}
+	}
+
+	return g.matrixGenerators
+}
+
+// Creates the AtlantisProjects for a directory, one per variant
+func (g *generator) createProjects(ctx context.Context, log log.Logger, sourcePath string) ([]*AtlantisProject, error) {
+	projects := []*AtlantisProject{}
+	for _, variantGenerator := range g.variantGenerators() {
+		variantProjects, err := variantGenerator.createProject(ctx, log, sourcePath)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, variantProjects...)
+	}
+
+	return projects, nil
+}
+
+// Creates the AtlantisProjects for a directory: one per workspace set through `atlantis_workspaces`, or else a
+// single one
+func (g *generator) createProject(ctx context.Context, log log.Logger, sourcePath string) ([]*AtlantisProject, error) {
+	dependencies, err := g.getDependencies(ctx, log, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// dependencies being nil is a sign from `getDependencies` that this project should be skipped
+	if dependencies == nil && !strings.HasSuffix(sourcePath, "terragrunt.stack.hcl") {
+		return nil, nil
+	}
+
+	// The unit was parsed while finding its dependencies already
+	direct, err := g.getDirectDependencies(ctx, log, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	locals := direct.locals
+
+	absoluteSourceDir := filepath.Dir(sourcePath) + string(filepath.Separator)
+	g.reportUnknownLocals(log, sourcePath, locals)
+
+	// If `atlantis_skip` is true on the module, then do not produce a project for it
+	if locals.Skip != nil && *locals.Skip {
+		return nil, nil
+	}
+
+	// Every workspace evaluates the module again, so its locals and dependencies can differ
+	if len(locals.Workspaces) > 0 && (g.variant == nil || g.variant.workspace == "") {
+		projects := []*AtlantisProject{}
+		for _, workspace := range locals.Workspaces {
+			workspaceProjects, err := g.forWorkspace(workspace).createProject(ctx, log, sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			projects = append(projects, workspaceProjects...)
+		}
+
+		return projects, nil
+	}
+
+	if g.opts.ExpandStacks && strings.HasSuffix(sourcePath, "terragrunt.stack.hcl") {
+		return g.createStackProjects(ctx, log, sourcePath, locals)
+	}
+
+	// All dependencies depend on their own .hcl file, and any tf files in their directory
+	relativeDependencies := []string{}
+
+	if strings.HasSuffix(sourcePath, "terragrunt.hcl") {
+		relativeDependencies = append(relativeDependencies, "terragrunt.hcl")
+	} else if strings.HasSuffix(sourcePath, "terragrunt.stack.hcl") {
+		relativeDependencies = append(relativeDependencies, "terragrunt.stack.hcl")
+	} else if strings.HasSuffix(sourcePath, "terragrunt.hcl.json") {
+		relativeDependencies = append(relativeDependencies, "terragrunt.hcl.json")
+	} else {
+		return nil, fmt.Errorf("unexpected terragrunt file name: %s", sourcePath)
+	}
+
+	relativeDependencies = append(relativeDependencies, "*.tf*")
+
+	// Add other dependencies based on their relative paths. We always want to output with Unix path separators
+	absoluteDependencies := []string{}
+	for _, dependencyPath := range dependencies {
+		absolutePath := dependencyPath
+		if !filepath.IsAbs(absolutePath) {
+			absolutePath = g.makePathAbsolute(dependencyPath, sourcePath)
+		}
+		relativePath, err := filepath.Rel(absoluteSourceDir, absolutePath)
+		if err != nil {
+			return nil, err
+		}
+
+		relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
+		absoluteDependencies = append(absoluteDependencies, absolutePath)
+	}
+
+	// Clean up the relative path to the format Atlantis expects
+	relativeSourceDir := strings.TrimPrefix(absoluteSourceDir, g.gitRoot)
+	relativeSourceDir = strings.TrimSuffix(relativeSourceDir, string(filepath.Separator))
+	if relativeSourceDir == "" {
+		relativeSourceDir = "."
+	}
+
+	project := g.newProject(relativeSourceDir, relativeDependencies)
+	project.configFiles = []string{filepath.Clean(sourcePath)}
+	project.dependencies = absoluteDependencies
+	err = g.applyRules(log, project)
+	if err != nil {
+		return nil, err
+	}
+	applyProjectLocals(project, locals)
+
+	// Terraform Cloud limits the workspace names to be less than 90 characters
+	// with letters, numbers, -, and _
+	// https://www.terraform.io/docs/cloud/workspaces/naming.html
+	// It is not clear from documentation whether the normal workspaces have those limitations
+	// However a workspace 97 chars long has been working perfectly.
+	// We are going to use the same name for both workspace & project name as it is unique.
+	projectName := invalidNameCharacters.ReplaceAllString(project.Dir, "_")
+
+	if g.opts.CreateProjectName {
+		project.Name = projectName
+	}
+
+	if g.opts.CreateWorkspace {
+		project.Workspace = projectName
+	}
+	g.nameVariantProject(project, projectName)
+
+	project, err = g.applyProjectOverrides(project, locals.ProjectOverrides, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*AtlantisProject{project}, nil
+}
+
+func (g *generator) createHclProject(ctx context.Context, log log.Logger, sourcePaths []string, workingDir string, projectHcl string) (*AtlantisProject, error) {
+	var projectHclDependencies []string
+	var childDependencies []string
+
+	projectHclFile := filepath.Join(workingDir, projectHcl)
+	parsingContext, err := g.newParsingContext(ctx, log, workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	locals, err := parseLocals(parsingContext, log, projectHclFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	g.reportUnknownLocals(log, projectHclFile, locals)
+
+	// If `atlantis_skip` is true on the module, then do not produce a project for it
+	if locals.Skip != nil && *locals.Skip {
+		return nil, nil
+	}
+
+	// if project markers are enabled, check if locals are set
+	markedProject := false
+	if locals.markedProject != nil {
+		markedProject = *locals.markedProject
+	}
+	if g.opts.UseProjectMarkers && !markedProject {
+		return nil, nil
+	}
+
+	// The project stands for its project hcl file and all its children
+	configFiles := []string{filepath.Clean(projectHclFile)}
+	absoluteDependencies := []string{}
+
+	if locals.ExtraAtlantisDependencies != nil {
+		for _, dep := range locals.ExtraAtlantisDependencies {
+			relDep, err := filepath.Rel(workingDir, dep)
+			if err != nil {
+				return nil, err
+			}
+			projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relDep))
+			absoluteDependencies = append(absoluteDependencies, dep)
+		}
+	}
+
+	// build dependencies for terragrunt childs in directories below project hcl file
+	for _, sourcePath := range sourcePaths {
+		dependencies, err := g.getDependencies(ctx, log, sourcePath)
+		if err != nil {
+			return nil, err
+		}
+		// dependencies being nil is a sign from `getDependencies` that this project should be skipped
+		if dependencies == nil {
+			// An excluded child only leaves out itself
+			if g.isExcluded(sourcePath) {
+				continue
+			}
+			return nil, nil
+		}
+
+		// All dependencies depend on their own .hcl file, and any tf files in their directory
+		relativeDependencies := []string{
+			"terragrunt.hcl",
+			"*.tf*",
+			"**/*.hcl",
+			"**/*.tf*",
+		}
+
+		// Add other dependencies based on their relative paths. We always want to output with Unix path separators
+		for _, dependencyPath := range dependencies {
+			absolutePath := dependencyPath
+			if !filepath.IsAbs(absolutePath) {
+				absolutePath = g.makePathAbsolute(dependencyPath, sourcePath)
+			}
+
+			relativePath, err := filepath.Rel(workingDir, absolutePath)
+			if err != nil {
+				return nil, err
+			}
+
+			if !strings.Contains(absolutePath, filepath.ToSlash(workingDir)) {
+				relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
+				absoluteDependencies = append(absoluteDependencies, absolutePath)
+			}
+		}
+
+		childDependencies = append(childDependencies, relativeDependencies...)
+		configFiles = append(configFiles, filepath.Clean(sourcePath))
+	}
+	dir, err := filepath.Rel(g.gitRoot, workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	project := g.newProject(dir, append(childDependencies, projectHclDependencies...))
+	project.configFiles = configFiles
+	project.dependencies = absoluteDependencies
+	err = g.applyRules(log, project)
+	if err != nil {
+		return nil, err
+	}
+	applyProjectLocals(project, locals)
+
+	// Terraform Cloud limits the workspace names to be less than 90 characters
+	// with letters, numbers, -, and _
+	// https://www.terraform.io/docs/cloud/workspaces/naming.html
+	// It is not clear from documentation whether the normal workspaces have those limitations
+	// However a workspace 97 chars long has been working perfectly.
+	// We are going to use the same name for both workspace & project name as it is unique.
+	projectName := invalidNameCharacters.ReplaceAllString(project.Dir, "_")
+
+	if g.opts.CreateProjectName {
+		project.Name = projectName
+	}
+
+	if g.opts.CreateWorkspace {
+		project.Workspace = projectName
+	}
+	g.nameVariantProject(project, projectName)
+
+	return g.applyProjectOverrides(project, locals.ProjectOverrides, projectHclFile)
+}
+
+// Finds the absolute paths of all arbitrary project hcl files
+func (g *generator) getAllTerragruntProjectHclFiles() (map[string][]string, error) {
+	projectHclFiles := g.opts.ProjectHclFiles
+	orderedHclFilePaths := map[string][]string{}
+	uniqueHclFileAbsPaths := map[string][]string{}
+	for _, projectHclFile := range projectHclFiles {
+		err := filepath.Walk(g.gitRoot, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && info.Name() == projectHclFile {
+				orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uniquePath := range orderedHclFilePaths[projectHclFile] {
+			uniqueAbsPath, err := filepath.Abs(uniquePath)
+			if err != nil {
+				return nil, err
+			}
+			uniqueHclFileAbsPaths[projectHclFile] = append(uniqueHclFileAbsPaths[projectHclFile], uniqueAbsPath)
+		}
+	}
+	return uniqueHclFileAbsPaths, nil
+}
+
+// Runs the whole generation pipeline, returning the resulting config without writing it anywhere
+func (g *generator) generate(ctx context.Context, log log.Logger) (*AtlantisConfig, error) {
+	workingDirs := []string{g.gitRoot}
+	projectHclDirMap := map[string][]string{}
+	var projectHclDirs []string
+	if len(g.opts.ProjectHclFiles) > 0 {
+		workingDirs = nil
+		// map [project-hcl-file] => directories containing project-hcl-file
+		var err error
+		projectHclDirMap, err = g.getAllTerragruntProjectHclFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, projectHclFile := range g.opts.ProjectHclFiles {
+			projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
+			workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
+		}
+		// parse terragrunt child modules outside the scope of projectHclDirs
+		if g.opts.CreateHclProjectExternalChilds {
+			workingDirs = append(workingDirs, g.gitRoot)
+		}
+	}
+	// Read in the old config, if it already exists
+	oldConfig, err := ReadOldConfig(log, g.opts.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+	config := AtlantisConfig{
+		Version:   3,
+		AutoMerge: g.opts.AutoMerge,
+	}
+	err = g.applyRepoSettings(&config, oldConfig)
+	if err != nil {
+		return nil, err
+	}
+	if oldConfig != nil {
+		// Keys this library doesn't know about were written by hand, so they're always kept
+		config.Extra = oldConfig.Extra
+	}
+	if oldConfig != nil && g.opts.PreserveWorkflows {
+		config.Workflows = oldConfig.Workflows
+	}
+	if oldConfig != nil && (g.opts.PreserveProjects || g.opts.ChangedSince != "") {
+		config.Projects = oldConfig.Projects
+	}
+	if oldConfig == nil && g.opts.ChangedSince != "" {
+		return nil, fmt.Errorf("incremental generation needs the config of an earlier run at %s", g.opts.OutputPath)
+	}
+
+	// With `opts.ExpandStacks`, stacks get a project per unit instead, so the catalogs they're generated from don't
+	catalogDirs, err := g.findStackCatalogDirs(ctx, log)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := sync.Mutex{}
+	groupContext := context.Background()
+	errGroup, _ := errgroup.WithContext(groupContext)
+	sem := semaphore.NewWeighted(g.opts.NumExecutors)
+
+	for _, workingDir := range workingDirs {
+		terragruntFiles, err := g.getAllTerragruntFiles(workingDir)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the units affected by the changed files are regenerated, all other projects are kept as they were
+		var incremental *incrementalGeneration
+		if g.opts.ChangedSince != "" {
+			incremental, err = g.planIncrementalGeneration(log, &config, terragruntFiles)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(projectHclDirs) == 0 || g.opts.CreateHclProjectChilds || (g.opts.CreateHclProjectExternalChilds && workingDir == g.gitRoot) {
+			// Concurrently looking all dependencies
+			for _, terragruntPath := range terragruntFiles {
+				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
+
+				// don't create atlantis projects already covered by project hcl file projects
+				skipProject := false
+				if g.opts.CreateHclProjectExternalChilds && workingDir == g.gitRoot && len(projectHclDirs) > 0 {
+					for _, projectHclDir := range projectHclDirs {
+						if strings.HasPrefix(terragruntPath, projectHclDir) {
+							skipProject = true
+							break
+						}
+					}
+				}
+				if g.opts.ExpandStacks {
+					skipProject = skipProject || isGeneratedByStack(terragruntPath) || isInCatalog(terragruntPath, catalogDirs)
+				}
+				if !incremental.includes(terragruntPath) {
+					skipProject = true
+				}
+				if skipProject {
+					continue
+				}
+				if err := sem.Acquire(ctx, 1); err != nil {
+					return nil, err
+				}
+
+				errGroup.Go(func() error {
+					defer sem.Release(1)
+					projects, err := g.createProjects(ctx, log, terragruntPath)
+					if err != nil {
+						return err
+					}
+
+					// Lock the list as only one goroutine should be writing to config.Projects at a time
+					lock.Lock()
+					defer lock.Unlock()
+					incremental.report(log, terragruntPath, projects)
+
+					// no projects and a nil err means this module is skipped
+					for _, project := range projects {
+						g.recordProject(terragruntPath, project.Dir)
+
+						// When preserving existing projects, we should update existing blocks instead of creating a
+						// duplicate, when generating something which already has representation
+						if g.opts.PreserveProjects {
+							if i := g.findPreservedProject(log, config.Projects, project); i >= 0 {
+								log.Info("Updated project for ", terragruntPath)
+								if err := preserveProjectKeys(log, config.Projects[i], project); err != nil {
+									return err
+								}
+								config.Projects[i] = *project
+							} else {
+								log.Info("Created project for ", terragruntPath)
+								config.Projects = append(config.Projects, *project)
+							}
+						} else {
+							log.Info("Created project for ", terragruntPath)
+							config.Projects = append(config.Projects, *project)
+						}
+					}
+
+					return nil
+				})
+			}
+
+			if err := errGroup.Wait(); err != nil {
+				return nil, err
+			}
+		}
+		if len(projectHclDirs) > 0 && workingDir != g.gitRoot {
+			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
+			err := sem.Acquire(ctx, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			errGroup.Go(func() error {
+				defer sem.Release(1)
+				for _, variantGenerator := range g.variantGenerators() {
+					project, err := variantGenerator.createHclProject(ctx, log, terragruntFiles, workingDir, projectHcl)
+					if err != nil {
+						return err
+					}
+					// if project and err are nil then skip this project
+					if err == nil && project == nil {
+						continue
+					}
+					// Lock the list as only one goroutine should be writing to config.Projects at a time
+					lock.Lock()
+					g.recordProject(filepath.Join(workingDir, projectHcl), project.Dir)
+
+					log.Info("Created "+projectHcl+" project for ", workingDir)
+					config.Projects = append(config.Projects, *project)
+					lock.Unlock()
+				}
+
+				return nil
+			})
+
+			if err := errGroup.Wait(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if g.opts.PrunePreserved {
+		g.prunePreservedProjects(ctx, log, &config)
+	}
+
+	// Sort the projects in config by Dir, then by Name to keep the variants of a dir in order
+	sort.Slice(config.Projects, func(i, j int) bool {
+		if config.Projects[i].Dir == config.Projects[j].Dir {
+			return config.Projects[i].Name < config.Projects[j].Name
+		}
+		return config.Projects[i].Dir < config.Projects[j].Dir
+	})
+
+	if g.opts.ExecutionOrderGroups || g.opts.DependsOn {
+		dependencies := g.projectDependencies(config.Projects)
+		err = g.breakProjectCycles(log, config.Projects, dependencies)
+		if err != nil {
+			return nil, err
+		}
+
+		// The execution order groups are built from the same graph as `depends_on`, so both always agree
+		if g.opts.DependsOn && g.opts.DependsOnReduce {
+			dependencies = reduceProjectDependencies(config.Projects, dependencies)
+		}
+
+		if g.opts.DependsOn {
+			for j := range config.Projects {
+				project := &config.Projects[j]
+				if isUnmanaged(*project) {
+					continue
+				}
+				dependsOnList := []string{}
+				for _, dependency := range dependencies[project] {
+					dependsOnList = append(dependsOnList, dependency.project.Name)
+				}
+				project.DependsOn = dependsOnList
+			}
+		}
+
+		if g.opts.ExecutionOrderGroups {
+			groups := executionOrderGroups(config.Projects, dependencies)
+			for j := range config.Projects {
+				if isUnmanaged(config.Projects[j]) {
+					continue
+				}
+				executionOrderGroup := groups[&config.Projects[j]]
+				config.Projects[j].ExecutionOrderGroup = &executionOrderGroup
+			}
+		}
+
+		// Sort by execution_order_group. Projects marked with `# managed: false` may not have one
+		if g.opts.ExecutionOrderGroups {
+			sort.Slice(config.Projects, func(i, j int) bool {
+				groupI, groupJ := executionOrderGroupOf(config.Projects[i]), executionOrderGroupOf(config.Projects[j])
+				if groupI == groupJ {
+					if config.Projects[i].Dir == config.Projects[j].Dir {
+						return config.Projects[i].Name < config.Projects[j].Name
+					}
+					return config.Projects[i].Dir < config.Projects[j].Dir
+				}
+				return groupI < groupJ
+			})
+		}
+	}
+
+	// What the projects were generated from only matters while generating, and would keep the config from
+	// comparing equal to the same config read back from a file
+	for i := range config.Projects {
+		config.Projects[i].configFiles = nil
+		config.Projects[i].dependencies = nil
+	}
+
+	if g.opts.CacheStats {
+		g.cache.logStats(log)
+	}
+
+	return &config, nil
+}
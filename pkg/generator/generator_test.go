@@ -0,0 +1,466 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+// Reads a golden file of the cmd package into a config
+func readGoldenConfig(t *testing.T, goldenFile string) *AtlantisConfig {
+	goldenContentsBytes, err := os.ReadFile(filepath.Join("..", "..", "cmd", "golden", goldenFile))
+	if err != nil {
+		t.Fatal("Failed to read golden file")
+	}
+
+	goldenContents := &AtlantisConfig{}
+	err = yaml.Unmarshal(goldenContentsBytes, goldenContents)
+	if err != nil {
+		t.Fatal("Failed to parse golden file")
+	}
+
+	return goldenContents
+}
+
+func TestGenerateRunsDoNotShareState(t *testing.T) {
+	cascading := DefaultOptions()
+	cascading.GitRoot = filepath.Join("..", "..", "test_examples", "chained_dependencies")
+
+	notCascading := DefaultOptions()
+	notCascading.GitRoot = filepath.Join("..", "..", "test_examples", "chained_dependencies")
+	notCascading.CascadeDependencies = false
+
+	// Both runs parse the same files concurrently, so any shared cache would leak between them
+	configs := make([]*AtlantisConfig, 2)
+	errGroup, ctx := errgroup.WithContext(context.Background())
+	for i, opts := range []Options{cascading, notCascading} {
+		errGroup.Go(func() error {
+			config, err := Generate(ctx, opts)
+			configs[i] = config
+			return err
+		})
+	}
+
+	if err := errGroup.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, readGoldenConfig(t, "chained_dependency.yaml"), configs[0])
+	assert.Equal(t, readGoldenConfig(t, "chained_dependency_no_flag.yaml"), configs[1])
+}
+
+func TestGenerateWithZeroValueOptions(t *testing.T) {
+	config, err := Generate(context.Background(), Options{
+		GitRoot: filepath.Join("..", "..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := readGoldenConfig(t, "basic.yaml")
+	expected.ParallelPlan = false
+	expected.ParallelApply = false
+
+	assert.Equal(t, expected, config)
+}
+
+func TestDependsOnReduceKeepsExecutionOrderGroups(t *testing.T) {
+	for _, example := range []string{"chained_dependencies", "execution_order"} {
+		t.Run(example, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.GitRoot = filepath.Join("..", "..", "test_examples", example)
+			opts.CreateProjectName = true
+			opts.DependsOn = true
+			opts.ExecutionOrderGroups = true
+			unreduced, err := Generate(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts.DependsOnReduce = true
+			reduced, err := Generate(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, len(unreduced.Projects), len(reduced.Projects))
+			dependsOnCount := map[bool]int{}
+			for i := range unreduced.Projects {
+				assert.Equal(t, unreduced.Projects[i].Name, reduced.Projects[i].Name)
+				assert.Equal(t, *unreduced.Projects[i].ExecutionOrderGroup, *reduced.Projects[i].ExecutionOrderGroup)
+				assert.Subset(t, unreduced.Projects[i].DependsOn, reduced.Projects[i].DependsOn)
+				dependsOnCount[false] += len(unreduced.Projects[i].DependsOn)
+				dependsOnCount[true] += len(reduced.Projects[i].DependsOn)
+			}
+			assert.Less(t, dependsOnCount[true], dependsOnCount[false])
+		})
+	}
+}
+
+func TestReducedDependenciesKeepExecutionOrderGroups(t *testing.T) {
+	// vpc <- db <- app, where app and api also depend on vpc directly, and app depends on api
+	projects := []AtlantisProject{{Dir: "vpc"}, {Dir: "db"}, {Dir: "api"}, {Dir: "app"}}
+	vpc, db, api, app := &projects[0], &projects[1], &projects[2], &projects[3]
+	full := map[*AtlantisProject][]projectDependency{
+		db:  {{project: vpc}},
+		api: {{project: vpc}},
+		app: {{project: vpc}, {project: db}, {project: api}},
+	}
+
+	reduced := reduceProjectDependencies(projects, full)
+	assert.Equal(t, []projectDependency{{project: db}, {project: api}}, reduced[app])
+	assert.Equal(t, executionOrderGroups(projects, full), executionOrderGroups(projects, reduced))
+}
+
+// Runs a generation, returning the cache of the run along with the config
+func generateWithCache(t *testing.T, opts Options) (*AtlantisConfig, *parseCache) {
+	g, logger, err := newGenerator(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := g.generate(context.Background(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config, g.cache
+}
+
+// Generates the config for `opts` without a cache, to compare the cached runs against
+func generateUncached(t *testing.T, opts Options) *AtlantisConfig {
+	opts.CacheDir = ""
+	config, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func quietOptions(gitRoot string) Options {
+	opts := DefaultOptions()
+	opts.GitRoot = gitRoot
+	opts.Logger = log.New(log.WithOutput(io.Discard), log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())))
+	return opts
+}
+
+func TestCacheDirReusesUnitsUntilTheirInputsChange(t *testing.T) {
+	gitRoot := t.TempDir()
+	if err := os.CopyFS(gitRoot, os.DirFS(filepath.Join("..", "..", "test_examples", "execution_order"))); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := quietOptions(gitRoot)
+	opts.CreateProjectName = true
+	opts.DependsOn = true
+	opts.CacheDir = t.TempDir()
+	expected := generateUncached(t, opts)
+
+	config, cache := generateWithCache(t, opts)
+	assert.Equal(t, expected, config)
+	assert.Equal(t, int64(0), cache.hits.Load())
+	assert.Equal(t, int64(5), cache.misses.Load())
+
+	// The settings read by app are parsed as a unit too when cascading
+	config, cache = generateWithCache(t, opts)
+	assert.Equal(t, expected, config)
+	assert.Equal(t, int64(5), cache.hits.Load())
+	assert.Equal(t, int64(0), cache.misses.Load())
+
+	// Both app and shared read the settings, so only their entries are out of date
+	writeTestFile(t, filepath.Join(gitRoot, "shared", "settings.hcl"), "locals {\n  region = \"us-east-1\"\n}\n")
+	config, cache = generateWithCache(t, opts)
+	assert.Equal(t, expected, config)
+	assert.Equal(t, int64(2), cache.hits.Load())
+	assert.Equal(t, int64(2), cache.invalidated.Load())
+
+	// Editing a unit gives it another key, while its dependents are still found in the cache
+	writeTestFile(t, filepath.Join(gitRoot, "vpc", "terragrunt.hcl"), "terraform {\n  source = \"git::https://example.com/modules.git//vpc\"\n}\n\nlocals {\n  atlantis_workflow = \"network\"\n}\n")
+	config, cache = generateWithCache(t, opts)
+	assert.Equal(t, generateUncached(t, opts), config)
+	assert.Equal(t, int64(4), cache.hits.Load())
+	assert.Equal(t, int64(1), cache.misses.Load())
+}
+
+func TestCacheDirNoticesConfigsFoundInParentFolders(t *testing.T) {
+	gitRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(gitRoot, "root.hcl"), "locals {\n  atlantis_workflow = \"root\"\n}\n")
+	writeTestFile(t, filepath.Join(gitRoot, "live", "app", "terragrunt.hcl"), `include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+terraform {
+  source = "git::https://example.com/modules.git//app"
+}
+`)
+
+	opts := quietOptions(gitRoot)
+	opts.CacheDir = t.TempDir()
+	config, _ := generateWithCache(t, opts)
+	assert.Equal(t, "root", config.Projects[0].Workflow)
+
+	// `find_in_parent_folders` now stops at the closer config, although no file the unit was parsed from changed
+	writeTestFile(t, filepath.Join(gitRoot, "live", "root.hcl"), "locals {\n  atlantis_workflow = \"live\"\n}\n")
+	config, cache := generateWithCache(t, opts)
+	assert.Equal(t, "live", config.Projects[0].Workflow)
+	assert.Equal(t, int64(1), cache.invalidated.Load())
+}
+
+func TestCacheDirNoticesDirsOfFileReferences(t *testing.T) {
+	gitRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(gitRoot, "app", "terragrunt.hcl"), "terraform {\n  source = \"../module\"\n}\n")
+	writeTestFile(t, filepath.Join(gitRoot, "module", "main.tf"), `variable "name" {}
+
+output "policy" {
+  value = file("${path.module}/policies/${var.name}.json")
+}
+`)
+
+	opts := quietOptions(gitRoot)
+	opts.CacheDir = t.TempDir()
+	config, _ := generateWithCache(t, opts)
+	assert.Equal(t, generateUncached(t, opts), config)
+	assert.NotContains(t, config.Projects[0].Autoplan.WhenModified, "../module/policies/*")
+
+	// The files of the dir are depended on once it exists, although no terraform file changed
+	writeTestFile(t, filepath.Join(gitRoot, "module", "policies", "admin.json"), "{}\n")
+	config, cache := generateWithCache(t, opts)
+	assert.Equal(t, generateUncached(t, opts), config)
+	assert.Contains(t, config.Projects[0].Autoplan.WhenModified, "../module/policies/*")
+	assert.Equal(t, int64(1), cache.invalidated.Load())
+}
+
+func TestCacheDirTracksEnvironmentVariables(t *testing.T) {
+	opts := quietOptions(filepath.Join("..", "..", "test_examples", "env_vars"))
+	opts.CleanEnv = true
+	opts.CacheDir = t.TempDir()
+
+	for _, env := range [][]string{{}, {"DEPLOY_ENV=prod", "UNRELATED=1"}, {}, {"DEPLOY_ENV=prod"}} {
+		opts.Env = env
+		config, _ := generateWithCache(t, opts)
+		assert.Equal(t, generateUncached(t, opts), config)
+	}
+
+	// Variables the unit never read don't invalidate its entry
+	opts.Env = []string{"DEPLOY_ENV=prod", "UNRELATED=2"}
+	_, cache := generateWithCache(t, opts)
+	assert.Equal(t, int64(1), cache.hits.Load())
+}
+
+func TestCacheDirKeepsVariantsApart(t *testing.T) {
+	opts := quietOptions(filepath.Join("..", "..", "test_examples", "variants", "matrix"))
+	opts.Matrix = []string{"TG_ENV=dev,prod", "COLOR=blue,green"}
+	opts.CacheDir = t.TempDir()
+	expected := generateUncached(t, opts)
+
+	for run := 0; run < 2; run++ {
+		config, cache := generateWithCache(t, opts)
+		assert.Equal(t, expected, config)
+		if run > 0 {
+			assert.Equal(t, int64(0), cache.misses.Load())
+		}
+	}
+}
+
+func TestCacheDirIsSafeForConcurrentRuns(t *testing.T) {
+	opts := quietOptions(filepath.Join("..", "..", "test_examples", "terragrunt-infrastructure-live-example"))
+	opts.CreateProjectName = true
+	opts.CacheDir = t.TempDir()
+	expected := generateUncached(t, opts)
+
+	configs := make([]*AtlantisConfig, 4)
+	errGroup, ctx := errgroup.WithContext(context.Background())
+	for i := range configs {
+		errGroup.Go(func() error {
+			config, err := Generate(ctx, opts)
+			configs[i] = config
+			return err
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range configs {
+		assert.Equal(t, expected, config)
+	}
+	config, cache := generateWithCache(t, opts)
+	assert.Equal(t, expected, config)
+	assert.Equal(t, int64(0), cache.misses.Load())
+}
+
+// Runs git in `dir`, failing the test when it fails
+func gitForTest(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func TestChangedSinceRegeneratesAffectedUnits(t *testing.T) {
+	gitRoot := t.TempDir()
+	if err := os.CopyFS(gitRoot, os.DirFS(filepath.Join("..", "..", "test_examples", "execution_order"))); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(gitRoot, "legacy", "terragrunt.hcl"), "terraform {\n  source = \"git::https://example.com/modules.git//legacy\"\n}\n")
+	gitForTest(t, gitRoot, "init", "--quiet")
+	gitForTest(t, gitRoot, "add", "--all")
+	gitForTest(t, gitRoot, "commit", "--quiet", "--message", "initial")
+
+	opts := quietOptions(gitRoot)
+	opts.CreateProjectName = true
+	opts.DependsOn = true
+	opts.OutputPath = filepath.Join(t.TempDir(), "atlantis.yaml")
+	config, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A hand edit of a project that isn't affected by the changes survives the incremental run
+	for i := range config.Projects {
+		if config.Projects[i].Dir == "vpc" {
+			config.Projects[i].Workflow = "hand-edited"
+		}
+	}
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, opts.OutputPath, string(configBytes))
+
+	writeTestFile(t, filepath.Join(gitRoot, "shared", "settings.hcl"), "locals {\n  region = \"us-east-1\"\n}\n")
+	writeTestFile(t, filepath.Join(gitRoot, "cache", "terragrunt.hcl"), "terraform {\n  source = \"git::https://example.com/modules.git//cache\"\n}\n")
+	if err := os.RemoveAll(filepath.Join(gitRoot, "legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected.Projects {
+		if expected.Projects[i].Dir == "vpc" {
+			expected.Projects[i].Workflow = "hand-edited"
+		}
+	}
+
+	output := bytes.Buffer{}
+	opts.Logger = log.New(log.WithOutput(&output), log.WithLevel(options.DefaultLogLevel), log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())))
+	opts.ChangedSince = "HEAD"
+	config, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, config)
+
+	assert.Contains(t, output.String(), "Regenerated project app, as shared/settings.hcl changed")
+	assert.Contains(t, output.String(), "Regenerated project shared, as shared/settings.hcl changed")
+	assert.Contains(t, output.String(), "Regenerated project cache, as cache/terragrunt.hcl is new")
+	assert.Contains(t, output.String(), "Removed the projects of legacy: legacy/terragrunt.hcl changed")
+	assert.NotContains(t, output.String(), "Regenerated project vpc")
+	assert.NotContains(t, output.String(), "Regenerated project db")
+}
+
+func TestChangedSinceWithUnknownRef(t *testing.T) {
+	gitRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(gitRoot, "app", "terragrunt.hcl"), "terraform {\n  source = \"git::https://example.com/modules.git//app\"\n}\n")
+	gitForTest(t, gitRoot, "init", "--quiet")
+	writeTestFile(t, filepath.Join(gitRoot, "atlantis.yaml"), "version: 3\nprojects: []\n")
+
+	opts := quietOptions(gitRoot)
+	opts.OutputPath = filepath.Join(gitRoot, "atlantis.yaml")
+	opts.ChangedSince = "no-such-branch"
+	_, err := Generate(context.Background(), opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown git ref "no-such-branch"`)
+	}
+}
+
+func BenchmarkGenerateInfrastructureLiveExample(b *testing.B) {
+	opts := DefaultOptions()
+	opts.GitRoot = filepath.Join("..", "..", "test_examples", "terragrunt-infrastructure-live-example")
+	opts.Logger = log.New(log.WithOutput(io.Discard), log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())))
+	opts.CreateProjectName = true
+	opts.DependsOn = true
+	opts.ExecutionOrderGroups = true
+
+	for b.Loop() {
+		_, err := Generate(context.Background(), opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUnmanagedProjectCommentsSurviveRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atlantis.yaml")
+	writeTestFile(t, path, `projects:
+- dir: generated
+- dir: handwritten # managed: false
+  workflow: custom
+`)
+
+	config, err := ReadOldConfig(quietOptions("").Logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, isUnmanaged(config.Projects[0]))
+	assert.True(t, isUnmanaged(config.Projects[1]))
+
+	content, err := MarshalConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(content), "# managed: false\n- autoplan:\n    enabled: false\n    when_modified: null\n  dir: handwritten\n")
+	assert.NotContains(t, string(content), "managed: false\n  dir: generated")
+}
+
+func TestProjectHclFilesInMissingRootReturnsError(t *testing.T) {
+	opts := quietOptions(filepath.Join(t.TempDir(), "missing"))
+	opts.ProjectHclFiles = []string{"env.hcl"}
+
+	_, err := Generate(context.Background(), opts)
+	assert.Error(t, err)
+}
+
+func TestPreservedProjectWarnsAboutReplacedWorkflow(t *testing.T) {
+	gitRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(gitRoot, "app", "terragrunt.hcl"), "terraform {\n  source = \"git::https://example.com/modules.git//app\"\n}\n")
+	writeTestFile(t, filepath.Join(gitRoot, "atlantis.yaml"), "version: 3\nprojects:\n- dir: app\n  workflow: handwritten\n")
+
+	output := bytes.Buffer{}
+	opts := quietOptions(gitRoot)
+	opts.Logger = log.New(log.WithOutput(&output), log.WithLevel(options.DefaultLogLevel), log.WithFormatter(format.NewFormatter(format.NewPrettyFormatPlaceholders())))
+	opts.OutputPath = filepath.Join(gitRoot, "atlantis.yaml")
+	opts.PreserveProjects = true
+	opts.DefaultWorkflow = "terragrunt"
+	config, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, config.Projects, 1) {
+		assert.Equal(t, "terragrunt", config.Projects[0].Workflow)
+	}
+	assert.Contains(t, output.String(), `Project app: the generated value of "workflow" replaces the one preserved from the old config`)
+}
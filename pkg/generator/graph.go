@@ -0,0 +1,260 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The reason one file depends on another
+type EdgeKind string
+
+const (
+	// The file is a parent included through an `include` block
+	EdgeInclude EdgeKind = "include"
+
+	// The file is another module referenced by a `dependency` or `dependencies` block
+	EdgeDependency EdgeKind = "dependency"
+
+	// The file belongs to a local terraform module used as a source
+	EdgeModuleSource EdgeKind = "module-source"
+
+	// The file is listed in `extra_atlantis_dependencies`
+	EdgeExtra EdgeKind = "extra"
+
+	// The file is a var file passed through `extra_arguments`
+	EdgeVarFile EdgeKind = "var-file"
+
+	// The file is read while parsing, by functions like `read_terragrunt_config`, `read_tfvars_file` or `file`
+	EdgeRead EdgeKind = "read"
+)
+
+// What a node of the dependency graph represents
+type NodeKind string
+
+const (
+	// A terragrunt config that became an Atlantis project
+	NodeProject NodeKind = "project"
+
+	// Any other file or glob a project depends on
+	NodeFile NodeKind = "file"
+)
+
+// A file in the dependency graph
+type Node struct {
+	// Path of the file relative to the git root, with Unix path separators
+	ID string `json:"id"`
+
+	Kind NodeKind `json:"kind"`
+
+	// The Atlantis project dir, only set for project nodes
+	Dir string `json:"dir,omitempty"`
+}
+
+// A dependency of one file on another
+type Edge struct {
+	// ID of the depending node
+	From string `json:"from"`
+
+	// ID of the node depended on
+	To string `json:"to"`
+
+	Kind EdgeKind `json:"kind"`
+}
+
+// The dependency graph of all projects, as discovered while generating the Atlantis config
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Collects edges and projects from concurrently running `getDependencies` calls
+type graphRecorder struct {
+	mtx sync.Mutex
+
+	// All edges, using absolute paths
+	edges map[Edge]bool
+
+	// Absolute path of each project's config file, mapped to the project dir
+	projects map[string]string
+}
+
+func newGraphRecorder() *graphRecorder {
+	return &graphRecorder{
+		edges:    map[Edge]bool{},
+		projects: map[string]string{},
+	}
+}
+
+// Records that the file at `from` depends on each of `targets`. Targets may be relative to `from`
+func (g *generator) recordEdges(from string, kind EdgeKind, targets ...string) {
+	g.addEdges(g.newEdges(from, kind, targets...))
+}
+
+// Builds the edges from the file at `from` to each of `targets`, without recording them. Targets may be relative
+// to `from`
+func (g *generator) newEdges(from string, kind EdgeKind, targets ...string) []Edge {
+	edges := []Edge{}
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(target) {
+			target = g.makePathAbsolute(target, from)
+		}
+
+		edges = append(edges, Edge{
+			From: filepath.Clean(from),
+			To:   filepath.Clean(target),
+			Kind: kind,
+		})
+	}
+
+	return edges
+}
+
+// Records edges built by `newEdges`
+func (g *generator) addEdges(edges []Edge) {
+	g.graph.mtx.Lock()
+	defer g.graph.mtx.Unlock()
+
+	for _, edge := range edges {
+		g.graph.edges[edge] = true
+	}
+}
+
+// Records that the config file at `path` produced a project for `dir`
+func (g *generator) recordProject(path string, dir string) {
+	g.graph.mtx.Lock()
+	defer g.graph.mtx.Unlock()
+
+	g.graph.projects[filepath.Clean(path)] = dir
+}
+
+// Converts an absolute path to a node ID
+func (g *generator) nodeID(path string) string {
+	relativePath, err := filepath.Rel(g.gitRoot, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	return filepath.ToSlash(relativePath)
+}
+
+// Turns everything recorded into a graph with stable ordering
+func (g *generator) buildGraph() *Graph {
+	nodes := map[string]Node{}
+	addNode := func(path string) string {
+		id := g.nodeID(path)
+		if _, ok := nodes[id]; ok {
+			return id
+		}
+
+		node := Node{ID: id, Kind: NodeFile}
+		if dir, ok := g.graph.projects[path]; ok {
+			node.Kind = NodeProject
+			node.Dir = dir
+		}
+		nodes[id] = node
+
+		return id
+	}
+
+	graph := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for path := range g.graph.projects {
+		addNode(path)
+	}
+	for edge := range g.graph.edges {
+		graph.Edges = append(graph.Edges, Edge{
+			From: addNode(edge.From),
+			To:   addNode(edge.To),
+			Kind: edge.Kind,
+		})
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		if graph.Edges[i].To != graph.Edges[j].To {
+			return graph.Edges[i].To < graph.Edges[j].To
+		}
+		return graph.Edges[i].Kind < graph.Edges[j].Kind
+	})
+
+	return graph
+}
+
+// BuildGraph runs the generator with `opts` and returns the dependency graph it walked, instead of the config.
+// The graph honours the same options, so filters, cascading and ignored dependency blocks shape it the same way
+func BuildGraph(ctx context.Context, opts Options) (*Graph, error) {
+	g, logger, err := newGenerator(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = g.generate(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.buildGraph(), nil
+}
+
+// DOT renders the graph in the Graphviz DOT language
+func (graph *Graph) DOT() string {
+	quote := func(value string) string {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString("digraph dependencies {\n")
+	builder.WriteString("  rankdir=LR;\n")
+	for _, node := range graph.Nodes {
+		shape := "note"
+		if node.Kind == NodeProject {
+			shape = "box"
+		}
+		builder.WriteString(fmt.Sprintf("  %s [shape=%s];\n", quote(node.ID), shape))
+	}
+	for _, edge := range graph.Edges {
+		builder.WriteString(fmt.Sprintf("  %s -> %s [label=%s];\n", quote(edge.From), quote(edge.To), quote(string(edge.Kind))))
+	}
+	builder.WriteString("}\n")
+
+	return builder.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (graph *Graph) Mermaid() string {
+	// Mermaid IDs can't contain most characters of a path, so nodes are numbered and labelled instead
+	ids := map[string]string{}
+	for i, node := range graph.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+	label := func(value string) string {
+		return `"` + strings.ReplaceAll(value, `"`, "#quot;") + `"`
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString("flowchart LR\n")
+	for _, node := range graph.Nodes {
+		if node.Kind == NodeProject {
+			builder.WriteString(fmt.Sprintf("  %s[%s]\n", ids[node.ID], label(node.ID)))
+		} else {
+			builder.WriteString(fmt.Sprintf("  %s(%s)\n", ids[node.ID], label(node.ID)))
+		}
+	}
+	for _, edge := range graph.Edges {
+		builder.WriteString(fmt.Sprintf("  %s -->|%s| %s\n", ids[edge.From], edge.Kind, ids[edge.To]))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+// Options control how the Atlantis config is generated. Each field mirrors one of the flags
+// of the `generate` command
+type Options struct {
+	// Path to the root directory of the git repo to build config for. Defaults to the current dir
+	GitRoot string
+
+	// Logger used while generating. Defaults to the logger in the context, then to a logger writing to stderr
+	Logger log.Logger
+
+	// The default value for autoplan settings. Can be overridden by locals
+	AutoPlan bool
+
+	// Enables the automerge setting for the repo
+	AutoMerge bool
+
+	// Ignore parent terragrunt configs (those which don't reference a terraform module)
+	IgnoreParentTerragrunt bool
+
+	// Create a project for the parent terragrunt configs (those which don't reference a terraform module)
+	CreateParentProject bool
+
+	// When true, dependencies found in `dependency` blocks will be ignored
+	IgnoreDependencyBlocks bool
+
+	// Enables plans and applies to happen in parallel
+	Parallel bool
+
+	// Use a different workspace for each project
+	CreateWorkspace bool
+
+	// Add a different name for each project
+	CreateProjectName bool
+
+	// Default terraform version to specify for all modules. Can be overridden by locals
+	DefaultTerraformVersion string
+
+	// Name of the workflow to be customized in the atlantis server
+	DefaultWorkflow string
+
+	// Paths or glob expressions to the directories to scope down the config for
+	FilterPaths []string
+
+	// Path of the atlantis.yaml being generated. When it already exists, it is read to preserve
+	// workflows and projects. Generate never writes to it
+	OutputPath string
+
+	// Preserves workflows from the old output file
+	PreserveWorkflows bool
+
+	// Preserves projects from the old output file to enable incremental builds
+	PreserveProjects bool
+
+	// When true, a module depends not only on its dependencies, but on all dependencies of its dependencies
+	CascadeDependencies bool
+
+	// Requirements that must be satisfied before `atlantis apply` can be run. Can be overridden by locals
+	DefaultApplyRequirements []string
+
+	// Number of executors used for parallel generation of projects. Values below 1 are treated as 1
+	NumExecutors int64
+
+	// Names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for
+	ProjectHclFiles []string
+
+	// Creates projects for terragrunt child modules below the directories containing ProjectHclFiles
+	CreateHclProjectChilds bool
+
+	// Creates projects for terragrunt child modules outside the directories containing ProjectHclFiles
+	CreateHclProjectExternalChilds bool
+
+	// Creates projects only for project hcl files with locals: atlantis_project = true
+	UseProjectMarkers bool
+
+	// Computes execution_order_group for projects
+	ExecutionOrderGroups bool
+
+	// Computes depends_on for projects. Requires CreateProjectName
+	DependsOn bool
+
+	// Keeps only the dependencies in depends_on that aren't implied by others, without changing the order
+	DependsOnReduce bool
+
+	// The project keys `atlantis_project_overrides` may set. Empty allows every key
+	AllowedOverrideKeys []string
+
+	// Overrides Parallel for plans when set
+	ParallelPlan *bool
+
+	// Overrides Parallel for applies when set
+	ParallelApply *bool
+
+	// Sets `abort_on_execution_order_fail` when not nil
+	AbortOnExecutionOrderFail *bool
+
+	// Sets `delete_source_branch_on_merge` for the whole repo when not nil
+	DeleteSourceBranchOnMerge *bool
+
+	// Sets `allowed_regexp_prefixes` when not nil
+	AllowedRegexpPrefixes []string
+
+	// Sets the `autodiscover` mode when not empty
+	Autodiscover string
+
+	// Ordered rules in the format `GLOB:KEY=VALUE;KEY=VALUE` assigning settings to the projects whose dir matches
+	// the glob. They apply after the defaults above and before locals
+	Rules []string
+
+	// Replaces `run_cmd`, `sops_decrypt_file` and the functions calling AWS by stubs while parsing, so untrusted
+	// configs can't run commands or use the credentials of the machine generating the config
+	SafeMode bool
+
+	// Values returned by the stubbed functions in safe mode, in the format `FUNCTION=VALUE`. Stubs without a
+	// fixture return an unknown value
+	SafeModeFixtures []string
+
+	// Starts the environment `get_env` sees while parsing from nothing, instead of from the process environment
+	CleanEnv bool
+
+	// Files of `KEY=VALUE` lines added to the environment `get_env` sees while parsing, in order
+	EnvFiles []string
+
+	// Variables in the format `KEY=VALUE` added to the environment `get_env` sees while parsing. They win over
+	// the env files
+	Env []string
+
+	// Axes in the format `KEY=VALUE,VALUE`. Every unit is evaluated once per combination of their values, with the
+	// keys set in the environment, and gets one project per combination
+	Matrix []string
+
+	// Creates a project for every unit a `terragrunt.stack.hcl` file generates below `.terragrunt-stack`, instead of
+	// one for the stack. The catalogs the units are generated from don't get projects then
+	ExpandStacks bool
+
+	// Warns about dependency cycles instead of failing, and breaks each by dropping the dependency closing it
+	AllowCycles bool
+
+	// Dir where the dependencies and locals of every unit are cached across runs, keyed by the content of the unit.
+	// Entries are only used while all files, env variables and options the unit was evaluated with are unchanged.
+	// Runs may share the dir. Empty disables the cache
+	CacheDir string
+
+	// Logs how many units were found in the cache once the config is generated
+	CacheStats bool
+
+	// A git ref. When set, only the units affected by the files changed between the ref and the working tree are
+	// regenerated, and merged into the config at `OutputPath`. The projects of deleted units are dropped, all other
+	// projects are kept as they are. Only the local git repository is read
+	ChangedSince string
+
+	// Drops the projects kept from the existing config whose dir has no terragrunt config left, or whose unit is
+	// now skipped through `atlantis_skip` or an `exclude` block. Each removal is logged
+	PrunePreserved bool
+
+	// Only logs the projects `PrunePreserved` would drop, keeping them in the config. The `generate` command doesn't
+	// write the config then
+	PruneDryRun bool
+
+	// How a generated project finds the preserved project it replaces: `dir-workspace-name` matches the same dir,
+	// workspace and name, `dir-workspace` ignores the name, and `dir` only compares dirs. Preserved projects marked
+	// with a `# managed: false` comment are never replaced, updated or removed
+	PreserveMatch string
+}
+
+// DefaultOptions returns the options used when no flags are passed to the `generate` command
+func DefaultOptions() Options {
+	return Options{
+		AutoPlan:                       false,
+		AutoMerge:                      false,
+		IgnoreParentTerragrunt:         true,
+		CreateParentProject:            false,
+		IgnoreDependencyBlocks:         false,
+		Parallel:                       true,
+		CreateWorkspace:                false,
+		CreateProjectName:              false,
+		DefaultTerraformVersion:        "",
+		DefaultWorkflow:                "",
+		FilterPaths:                    []string{},
+		OutputPath:                     "",
+		PreserveWorkflows:              true,
+		PreserveProjects:               false,
+		CascadeDependencies:            true,
+		DefaultApplyRequirements:       []string{},
+		NumExecutors:                   15,
+		ProjectHclFiles:                []string{},
+		CreateHclProjectChilds:         false,
+		CreateHclProjectExternalChilds: true,
+		UseProjectMarkers:              false,
+		ExecutionOrderGroups:           false,
+		DependsOn:                      false,
+		DependsOnReduce:                false,
+		AllowedOverrideKeys:            []string{},
+		Rules:                          []string{},
+		SafeMode:                       false,
+		SafeModeFixtures:               []string{},
+		CleanEnv:                       false,
+		EnvFiles:                       []string{},
+		Env:                            []string{},
+		Matrix:                         []string{},
+		ExpandStacks:                   false,
+		AllowCycles:                    false,
+		CacheDir:                       "",
+		CacheStats:                     false,
+		ChangedSince:                   "",
+		PrunePreserved:                 false,
+		PruneDryRun:                    false,
+		PreserveMatch:                  "dir-workspace-name",
+	}
+}
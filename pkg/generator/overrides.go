@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Converts a value to its generic JSON representation
+func toGenericMap(value interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	generic := map[string]interface{}{}
+	err = json.Unmarshal(bytes, &generic)
+	return generic, err
+}
+
+// Merges `override` into `base`, returning a new map. Nested objects are merged key by key, any other value
+// replaces the one in `base`, and a null value removes the key
+func deepMerge(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = deepMerge(baseMap, overrideMap)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// Decodes a generic project, keeping the keys AtlantisProject doesn't model in `Extra`
+func projectFromMap(generic map[string]interface{}) (*AtlantisProject, error) {
+	bytes, err := json.Marshal(generic)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &AtlantisProject{}
+	err = json.Unmarshal(bytes, project)
+	if err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// Deep merges the `atlantis_project_overrides` local into a generated project
+func (g *generator) applyProjectOverrides(project *AtlantisProject, overrides map[string]interface{}, path string) (*AtlantisProject, error) {
+	if len(overrides) == 0 {
+		return project, nil
+	}
+
+	if len(g.opts.AllowedOverrideKeys) > 0 {
+		allowedKeys := map[string]bool{}
+		for _, key := range g.opts.AllowedOverrideKeys {
+			allowedKeys[key] = true
+		}
+
+		keys := []string{}
+		for key := range overrides {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if !allowedKeys[key] {
+				return nil, fmt.Errorf("%s: atlantis_project_overrides sets %q, which is not one of the allowed override keys: %s", path, key, strings.Join(g.opts.AllowedOverrideKeys, ", "))
+			}
+		}
+	}
+
+	generic, err := toGenericMap(project)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden, err := projectFromMap(deepMerge(generic, overrides))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid atlantis_project_overrides: %w", path, err)
+	}
+	overridden.variant = project.variant
+	overridden.configFiles = project.configFiles
+	overridden.dependencies = project.dependencies
+
+	return overridden, nil
+}
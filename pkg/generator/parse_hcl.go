@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"golang.org/x/sync/singleflight"
+)
+
+// Everything generation needs from one terragrunt config. It's parsed once per generator and shared by resolving the
+// dependencies of the config, merging its locals and creating its project
+type parsedUnit struct {
+	// Set when the config has no `include` block and no terraform source, so it's likely the parent of other configs
+	isParent bool
+
+	// The `include` blocks, in order
+	includes []config.IncludeConfig
+
+	// The locals of the config, merged over the locals of the configs it includes
+	locals ResolvedLocals
+
+	// The partially parsed config, with its terraform, dependency, feature and exclude blocks. Nil for parents
+	// skipped with `opts.IgnoreParentTerragrunt`
+	config *config.TerragruntConfig
+
+	// Absolute paths of the files read while parsing, other than the config itself and the configs it includes
+	filesRead []string
+
+	// The variables read through `get_env` while parsing, nil when unset. Only tracked with the parse cache
+	envRead map[string]*string
+}
+
+// The configs parsed so far by a generator, by absolute path
+type parsedUnitCache struct {
+	requestGroup singleflight.Group
+
+	mtx  sync.RWMutex
+	data map[string]parsedUnitOutput
+}
+
+type parsedUnitOutput struct {
+	unit *parsedUnit
+	err  error
+}
+
+func newParsedUnitCache() *parsedUnitCache {
+	return &parsedUnitCache{data: map[string]parsedUnitOutput{}}
+}
+
+func (m *parsedUnitCache) get(k string) (parsedUnitOutput, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	v, ok := m.data[k]
+	return v, ok
+}
+
+func (m *parsedUnitCache) set(k string, v parsedUnitOutput) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.data[k] = v
+}
+
+// Parses the terragrunt config at `path`, or returns it from the cache when it was parsed already. The config is
+// always evaluated as itself, no matter which config it was first reached from
+func (g *generator) parseUnit(ctx context.Context, log log.Logger, path string) (*parsedUnit, error) {
+	path = filepath.Clean(path)
+	res, err, _ := g.units.requestGroup.Do(path, func() (interface{}, error) {
+		if cachedResult, ok := g.units.get(path); ok {
+			return cachedResult.unit, cachedResult.err
+		}
+
+		unit, err := g.parseUnitFile(ctx, log, path)
+		g.units.set(path, parsedUnitOutput{unit: unit, err: err})
+		return unit, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*parsedUnit), nil
+}
+
+func (g *generator) parseUnitFile(ctx context.Context, log log.Logger, path string) (*parsedUnit, error) {
+	parsingContext, err := g.newParsingContext(ctx, log, path)
+	if err != nil {
+		return nil, err
+	}
+	reads := newEnvReads()
+	if g.cache != nil {
+		trackedFunctions := withTrackedEnv(g.stubbedFunctions, parsingContext.ParsingContext, log, reads)
+		parsingContext = parsingContext.WithStubbedFunctions(trackedFunctions)
+	}
+
+	// Dependencies are parsed too when cascading, and they may be any file read while parsing, so nothing is reported
+	// until the file turns out to be a config
+	parserOptions := parsingContext.ParsingContext.ParserOptions
+	quietParser := hclparse.NewParser(append(
+		slices.Clone(parserOptions),
+		hclparse.WithDiagnosticsWriter(io.Discard, true),
+		hclparse.WithLogger(discardLogs(log)),
+	)...)
+	file, err := quietParser.ParseFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := &parsedUnit{}
+	defer func() {
+		unit.envRead = reads.snapshot()
+	}()
+	unit.isParent, err = isParentConfig(parsingContext, log, file)
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range parserOptions {
+		file.Parser = option(file.Parser)
+	}
+
+	// Parents don't get projects then, so nothing else is needed from them. Stack files still need their locals,
+	// which apply to the projects of their units
+	isStackFile := filepath.Base(path) == config.DefaultStackFile
+	if unit.isParent && g.opts.IgnoreParentTerragrunt && !isStackFile {
+		return unit, nil
+	}
+
+	baseBlocks, err := parsingContext.decodeBaseBlocksOfFile(log, file, nil)
+	if err != nil {
+		return nil, err
+	}
+	if baseBlocks.TrackInclude != nil {
+		unit.includes = baseBlocks.TrackInclude.CurrentList
+	}
+	unit.locals, err = resolveIncludedLocals(parsingContext, log, path, baseBlocks, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	partialParsingContext, err := NewParsingContextWithDecodeList(parsingContext, log)
+	if err != nil {
+		return nil, err
+	}
+	if !unit.isParent || !g.opts.IgnoreParentTerragrunt {
+		unit.config, err = partialParsingContext.partialParseFile(log, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	includedPaths := map[string]bool{}
+	for _, include := range unit.includes {
+		includedPaths[filepath.Clean(include.Path)] = true
+	}
+	for _, filesRead := range []*[]string{parsingContext.ParsingContext.FilesRead, partialParsingContext.ParsingContext.FilesRead} {
+		if filesRead == nil {
+			continue
+		}
+		for _, readFile := range *filesRead {
+			readFile = filepath.Clean(readFile)
+			if readFile == path || includedPaths[readFile] {
+				continue
+			}
+			unit.filesRead = append(unit.filesRead, readFile)
+		}
+	}
+
+	return unit, nil
+}
+
+// Not all modules need an include statement, as they could define everything in one file without a parent
+// The key signifiers of a parent are:
+//   - no include statement
+//   - no terraform source defined
+//
+// If both of those are true, it is likely a parent module
+func isParentConfig(ctx *TerragruntParsingContext, log log.Logger, file *hclparse.File) (bool, error) {
+	evalContext, err := createTerragruntEvalContext(ctx.ParsingContext, log, file.ConfigPath)
+	if err != nil {
+		return false, err
+	}
+
+	// Decoding the file also labels a bare `include` block, so the blocks decoded below can be told apart
+	terragruntIncludeList := terragruntIncludeMultiple{}
+	err = file.Decode(&terragruntIncludeList, evalContext)
+	if err != nil {
+		return false, err
+	}
+
+	// If the file has any `include` blocks it is not a parent
+	if len(terragruntIncludeList.Include) > 0 {
+		return false, nil
+	}
+
+	// We don't need to check the errors/diagnostics coming from decoding, as when errors come up,
+	// it will leave the partially parsed result in the output object.
+	var parsed parsedHcl
+	_ = decodeHcl(file, evalContext, &parsed)
+
+	// If the file does not define a terraform source block, it is likely a parent (though not guaranteed)
+	return parsed.Terraform == nil || parsed.Terraform.Source == nil, nil
+}
+
+// decodeHcl decodes the parsed HCL into the struct specified by out, without reporting the diagnostics
+func decodeHcl(file *hclparse.File, evalContext *hcl.EvalContext, out interface{}) (err error) {
+	// The HCL2 parser and especially cty conversions will panic in many types of errors, so we have to recover from
+	// those panics here and convert them to normal errors
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = hclparse.PanicWhileParsingConfigError{RecoveredValue: recovered, ConfigFile: file.ConfigPath}
+		}
+	}()
+
+	decodeDiagnostics := gohcl.DecodeBody(file.Body, evalContext, out)
+	if decodeDiagnostics != nil && decodeDiagnostics.HasErrors() {
+		return decodeDiagnostics
+	}
+
+	return nil
+}
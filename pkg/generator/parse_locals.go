@@ -0,0 +1,350 @@
+package generator
+
+// Terragrunt doesn't give us an easy way to access all of the Locals from a module
+// in an easy to digest way. This file is mostly just follows along how Terragrunt
+// parses the `locals` blocks and evaluates their contents.
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	deprecatedConfig "github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// ResolvedLocals are the parsed result of local values this module cares about
+type ResolvedLocals struct {
+	// The Atlantis workflow to use for some project
+	AtlantisWorkflow string
+
+	// Apply requirements to override the global `--apply-requirements` flag
+	ApplyRequirements []string
+
+	// Requirements that must be satisfied before `atlantis plan` can be run
+	PlanRequirements []string
+
+	// Requirements that must be satisfied before `atlantis import` can be run
+	ImportRequirements []string
+
+	// Regex of the base branches this project is planned for
+	Branch string
+
+	// When the project's repo lock is acquired: on_plan, on_apply or disabled
+	RepoLocksMode string
+
+	// If set, enables custom policy checks for the project
+	CustomPolicyCheck *bool
+
+	// The commands whose PR comments are silenced, e.g. plan or apply
+	SilencePRComments []string
+
+	// The distribution of terraform to use, e.g. terraform or opentofu
+	TerraformDistribution string
+
+	// If set, the source branch is deleted once the PR is merged
+	DeleteSourceBranchOnMerge *bool
+
+	// Arbitrary keys deep merged into the generated project
+	ProjectOverrides map[string]interface{}
+
+	// Extra dependencies that can be hardcoded in config
+	ExtraAtlantisDependencies []string
+
+	// If set, a single module will have autoplan turned to this setting
+	AutoPlan *bool
+
+	// If set to true, the module will not be included in the output
+	Skip *bool
+
+	// Terraform version to use just for this project
+	TerraformVersion string
+
+	// Terraform workspaces to create a project for each, instead of a single project
+	Workspaces []string
+
+	// If set to true, create Atlantis project
+	markedProject *bool
+
+	// Locals left unknown by the functions stubbed in safe mode, which are ignored
+	unknownLocals []unknownLocal
+}
+
+// Merges in values from a child into a parent set of `local` values
+func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLocals {
+	if child.AtlantisWorkflow != "" {
+		parent.AtlantisWorkflow = child.AtlantisWorkflow
+	}
+
+	if child.TerraformVersion != "" {
+		parent.TerraformVersion = child.TerraformVersion
+	}
+
+	if child.Workspaces != nil {
+		parent.Workspaces = child.Workspaces
+	}
+
+	if child.AutoPlan != nil {
+		parent.AutoPlan = child.AutoPlan
+	}
+
+	if child.Skip != nil {
+		parent.Skip = child.Skip
+	}
+
+	if child.markedProject != nil {
+		parent.markedProject = child.markedProject
+	}
+
+	if child.ApplyRequirements != nil || len(child.ApplyRequirements) > 0 {
+		parent.ApplyRequirements = child.ApplyRequirements
+	}
+
+	if child.PlanRequirements != nil {
+		parent.PlanRequirements = child.PlanRequirements
+	}
+
+	if child.ImportRequirements != nil {
+		parent.ImportRequirements = child.ImportRequirements
+	}
+
+	if child.Branch != "" {
+		parent.Branch = child.Branch
+	}
+
+	if child.RepoLocksMode != "" {
+		parent.RepoLocksMode = child.RepoLocksMode
+	}
+
+	if child.CustomPolicyCheck != nil {
+		parent.CustomPolicyCheck = child.CustomPolicyCheck
+	}
+
+	if child.SilencePRComments != nil {
+		parent.SilencePRComments = child.SilencePRComments
+	}
+
+	if child.TerraformDistribution != "" {
+		parent.TerraformDistribution = child.TerraformDistribution
+	}
+
+	if child.DeleteSourceBranchOnMerge != nil {
+		parent.DeleteSourceBranchOnMerge = child.DeleteSourceBranchOnMerge
+	}
+
+	parent.ExtraAtlantisDependencies = append(parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies...)
+	parent.unknownLocals = append(parent.unknownLocals, child.unknownLocals...)
+
+	if child.ProjectOverrides != nil {
+		parent.ProjectOverrides = deepMerge(parent.ProjectOverrides, child.ProjectOverrides)
+	}
+
+	return parent
+}
+
+// Parses a given file, returning a map of all it's `local` values
+func parseLocals(ctx *TerragruntParsingContext, log log.Logger, path string, includeFromChild *deprecatedConfig.IncludeConfig) (ResolvedLocals, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ParsingContext.TerragruntOptions.WorkingDir, path)
+	}
+	// Decode just the Base blocks. See the function docs for DecodeBaseBlocks for more info on what base blocks are.
+	baseBlocks, err := ctx.DecodeBaseBlocks(log, path, includeFromChild)
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+
+	return resolveIncludedLocals(ctx, log, path, baseBlocks, includeFromChild)
+}
+
+// Resolves the locals of the decoded base blocks of the file at `path`, merged over the locals of the files it includes
+func resolveIncludedLocals(ctx *TerragruntParsingContext, log log.Logger, path string, baseBlocks *deprecatedConfig.DecodedBaseBlocks, includeFromChild *deprecatedConfig.IncludeConfig) (ResolvedLocals, error) {
+	// Recurse on the parent to merge in the locals from that file
+	mergedParentLocals := ResolvedLocals{}
+	if baseBlocks.TrackInclude != nil && includeFromChild == nil {
+		for _, includeConfig := range baseBlocks.TrackInclude.CurrentList {
+			parentLocals, _ := parseLocals(ctx, log, includeConfig.Path, &includeConfig)
+			mergedParentLocals = mergeResolvedLocals(mergedParentLocals, parentLocals)
+		}
+	}
+	childLocals, err := resolveLocals(path, *baseBlocks.Locals)
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+	return mergeResolvedLocals(mergedParentLocals, childLocals), nil
+}
+
+// Converts a list of strings from a local. An empty list stays non-nil, so it can override a parent value
+func ctyStringList(name string, value cty.Value) ([]string, error) {
+	list := []string{}
+	if !value.Type().IsListType() && !value.Type().IsTupleType() {
+		return nil, fmt.Errorf("%s must be a list of strings", name)
+	}
+
+	it := value.ElementIterator()
+	for it.Next() {
+		pos, val := it.Element()
+		if !val.Type().Equals(cty.String) {
+			posInt, _ := pos.AsBigFloat().Int64()
+			return nil, fmt.Errorf("%s contains non-string value at position %d", name, posInt)
+		}
+		list = append(list, val.AsString())
+	}
+
+	return list, nil
+}
+
+func resolveLocals(path string, localsAsCty cty.Value) (ResolvedLocals, error) {
+	resolved := ResolvedLocals{}
+	var err error
+
+	// Return an empty set of locals if no `locals` block was present
+	if localsAsCty == cty.NilVal {
+		return resolved, nil
+	}
+	rawLocals := localsAsCty.AsValueMap()
+
+	// Unknown values can't be converted, so they are left out as if they weren't set
+	resolved.unknownLocals = findUnknownLocals(path, rawLocals)
+	for _, local := range resolved.unknownLocals {
+		delete(rawLocals, local.name)
+	}
+
+	workflowValue, ok := rawLocals["atlantis_workflow"]
+	if ok {
+		resolved.AtlantisWorkflow = workflowValue.AsString()
+	}
+
+	versionValue, ok := rawLocals["atlantis_terraform_version"]
+	if ok {
+		resolved.TerraformVersion = versionValue.AsString()
+	}
+
+	autoPlanValue, ok := rawLocals["atlantis_autoplan"]
+	if ok {
+		hasValue := autoPlanValue.True()
+		resolved.AutoPlan = &hasValue
+	}
+
+	skipValue, ok := rawLocals["atlantis_skip"]
+	if ok {
+		hasValue := skipValue.True()
+		resolved.Skip = &hasValue
+	}
+
+	applyReqs, ok := rawLocals["atlantis_apply_requirements"]
+	if ok {
+		resolved.ApplyRequirements, err = ctyStringList("atlantis_apply_requirements", applyReqs)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	planReqs, ok := rawLocals["atlantis_plan_requirements"]
+	if ok {
+		resolved.PlanRequirements, err = ctyStringList("atlantis_plan_requirements", planReqs)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	importReqs, ok := rawLocals["atlantis_import_requirements"]
+	if ok {
+		resolved.ImportRequirements, err = ctyStringList("atlantis_import_requirements", importReqs)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	workspaces, ok := rawLocals["atlantis_workspaces"]
+	if ok {
+		resolved.Workspaces, err = ctyStringList("atlantis_workspaces", workspaces)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	branchValue, ok := rawLocals["atlantis_branch"]
+	if ok {
+		resolved.Branch = branchValue.AsString()
+	}
+
+	repoLocksValue, ok := rawLocals["atlantis_repo_locks"]
+	if ok {
+		if !repoLocksValue.Type().IsObjectType() && !repoLocksValue.Type().IsMapType() {
+			return resolved, fmt.Errorf("atlantis_repo_locks must be an object like { mode = \"on_apply\" }")
+		}
+		modeValue, ok := repoLocksValue.AsValueMap()["mode"]
+		if !ok || !modeValue.Type().Equals(cty.String) {
+			return resolved, fmt.Errorf("atlantis_repo_locks must set a string mode")
+		}
+		resolved.RepoLocksMode = modeValue.AsString()
+	}
+
+	customPolicyCheckValue, ok := rawLocals["atlantis_custom_policy_check"]
+	if ok {
+		hasValue := customPolicyCheckValue.True()
+		resolved.CustomPolicyCheck = &hasValue
+	}
+
+	silencePRComments, ok := rawLocals["atlantis_silence_pr_comments"]
+	if ok {
+		resolved.SilencePRComments, err = ctyStringList("atlantis_silence_pr_comments", silencePRComments)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	distributionValue, ok := rawLocals["atlantis_terraform_distribution"]
+	if ok {
+		resolved.TerraformDistribution = distributionValue.AsString()
+	}
+
+	deleteSourceBranchValue, ok := rawLocals["atlantis_delete_source_branch_on_merge"]
+	if ok {
+		hasValue := deleteSourceBranchValue.True()
+		resolved.DeleteSourceBranchOnMerge = &hasValue
+	}
+
+	markedProject, ok := rawLocals["atlantis_project"]
+	if ok {
+		hasValue := markedProject.True()
+		resolved.markedProject = &hasValue
+	}
+
+	projectOverrides, ok := rawLocals["atlantis_project_overrides"]
+	if ok {
+		if !projectOverrides.Type().IsObjectType() && !projectOverrides.Type().IsMapType() {
+			return resolved, fmt.Errorf("atlantis_project_overrides must be an object")
+		}
+		overridesJSON, err := ctyjson.Marshal(projectOverrides, projectOverrides.Type())
+		if err != nil {
+			return resolved, err
+		}
+		resolved.ProjectOverrides = map[string]interface{}{}
+		err = json.Unmarshal(overridesJSON, &resolved.ProjectOverrides)
+		if err != nil {
+			return resolved, err
+		}
+	}
+
+	extraDependenciesAsCty, ok := rawLocals["extra_atlantis_dependencies"]
+	if ok {
+		it := extraDependenciesAsCty.ElementIterator()
+		for it.Next() {
+			pos, val := it.Element()
+			if !val.Type().Equals(cty.String) {
+				posInt, _ := pos.AsBigFloat().Int64()
+				return resolved, fmt.Errorf("extra_atlantis_dependencies contains non-string value at position %d", posInt)
+			}
+
+			resolved.ExtraAtlantisDependencies = append(
+				resolved.ExtraAtlantisDependencies,
+				filepath.ToSlash(val.AsString()),
+			)
+		}
+	}
+
+	return resolved, nil
+}
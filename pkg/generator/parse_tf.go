@@ -0,0 +1,188 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var localModuleSourcePrefixes = []string{
+	"./",
+	"../",
+	".\\",
+	"..\\",
+}
+
+// Terraform functions whose first argument is the path of a file they read
+var fileReadingTerraformFunctions = map[string]bool{
+	"file":         true,
+	"templatefile": true,
+}
+
+// Finds the files the terraform module at `path` and the local modules it calls depend on. Also returns the dirs
+// whose existence decided which files those are, see `resolveStaticPath`
+func (g *generator) parseTerraformLocalModuleSource(log log.Logger, path string) ([]string, []string, error) {
+	module, diags := tfconfig.LoadModule(path)
+	// modules, diags := parser.loadConfigDir(path)
+	if diags.HasErrors() {
+		return nil, nil, errors.New(diags.Error())
+	}
+
+	var sourceMap = map[string]bool{}
+	probedDirs := []string{}
+	for _, mc := range module.ModuleCalls {
+		if isLocalTerraformModuleSource(mc.Source) {
+			modulePath := filepath.Join(path, mc.Source)
+			modulePathGlob := filepath.Join(modulePath, "*.tf*")
+
+			if _, exists := sourceMap[modulePathGlob]; exists {
+				continue
+			}
+			sourceMap[modulePathGlob] = true
+
+			// find local module source recursively
+			subSources, subProbedDirs, err := g.parseTerraformLocalModuleSource(log, modulePath)
+			if err != nil {
+				return nil, nil, err
+			}
+			probedDirs = append(probedDirs, subProbedDirs...)
+
+			for _, subSource := range subSources {
+				sourceMap[subSource] = true
+			}
+		}
+	}
+
+	fileReferences, fileProbedDirs, err := g.parseTerraformFileReferences(log, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	probedDirs = append(probedDirs, fileProbedDirs...)
+	for _, fileReference := range fileReferences {
+		sourceMap[fileReference] = true
+	}
+
+	var sources = []string{}
+	for source := range sourceMap {
+		sources = append(sources, source)
+	}
+
+	return sources, probedDirs, nil
+}
+
+// Finds the files the terraform module at `path` reads through `file` and `templatefile` calls relative to
+// `path.module`. When only the start of a path is static, e.g. `"${path.module}/policies/${var.name}.json"`, all files
+// in the directory it names are returned instead, along with the dirs looked for that way. A warning is logged for
+// every call that can't be resolved
+func (g *generator) parseTerraformFileReferences(log log.Logger, path string) ([]string, []string, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tfFiles, err := filepath.Glob(filepath.Join(absolutePath, "*.tf"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"module": cty.StringVal(absolutePath),
+			}),
+		},
+	}
+
+	references := []string{}
+	probedDirs := []string{}
+	parser := hclparse.NewParser()
+	for _, tfFile := range tfFiles {
+		file, diags := parser.ParseHCLFile(tfFile)
+		if diags.HasErrors() {
+			return nil, nil, errors.New(diags.Error())
+		}
+
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			call, ok := node.(*hclsyntax.FunctionCallExpr)
+			if !ok || !fileReadingTerraformFunctions[call.Name] || len(call.Args) == 0 {
+				return nil
+			}
+
+			reference, probedDir, resolved := resolveStaticPath(call.Args[0], evalContext)
+			if probedDir != "" {
+				probedDirs = append(probedDirs, probedDir)
+			}
+			if !resolved || !filepath.IsAbs(reference) {
+				position := call.Range().String()
+				if _, warned := g.unresolvedFileReferences.LoadOrStore(position, true); !warned {
+					log.Warnf("Can't statically resolve the file read by %s() at %s, add it to extra_atlantis_dependencies instead", call.Name, position)
+				}
+				return nil
+			}
+
+			references = append(references, filepath.Clean(reference))
+			return nil
+		})
+	}
+
+	return references, probedDirs, nil
+}
+
+// Evaluates the path argument of a file reading function. When the full path isn't static, the directory named by
+// the static start of a template is returned as a glob matching its files, if it exists. The directory looked for
+// is returned either way, as whether it exists decides the result
+func resolveStaticPath(expr hclsyntax.Expression, evalContext *hcl.EvalContext) (string, string, bool) {
+	value, diags := expr.Value(evalContext)
+	if !diags.HasErrors() && value.IsWhollyKnown() && !value.IsNull() && value.Type() == cty.String {
+		return value.AsString(), "", true
+	}
+
+	template, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok {
+		return "", "", false
+	}
+
+	prefix := ""
+	for _, part := range template.Parts {
+		partValue, diags := part.Value(evalContext)
+		if diags.HasErrors() || !partValue.IsWhollyKnown() || partValue.IsNull() || partValue.Type() != cty.String {
+			break
+		}
+		prefix += partValue.AsString()
+	}
+
+	// Only a prefix ending in a complete directory can be turned into a glob
+	separator := strings.LastIndex(prefix, "/")
+	if separator <= 0 {
+		return "", "", false
+	}
+	dir := prefix[:separator]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", dir, false
+	}
+
+	return filepath.Join(dir, "*"), dir, true
+}
+
+func isLocalTerraformModuleSource(raw string) bool {
+	for _, prefix := range localModuleSourcePrefixes {
+		if strings.HasPrefix(raw, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+// How a generated project finds the preserved project it replaces
+const (
+	// Same dir, workspace and name
+	preserveMatchDirWorkspaceName = "dir-workspace-name"
+
+	// Same dir and workspace, whatever the name
+	preserveMatchDirWorkspace = "dir-workspace"
+
+	// Same dir. Variants still need the same name, as their dir is shared
+	preserveMatchDir = "dir"
+)
+
+var preserveMatchStrategies = []string{preserveMatchDirWorkspaceName, preserveMatchDirWorkspace, preserveMatchDir}
+
+// The comment marking a project of the old config as written by hand. Such a project is never updated, replaced or
+// removed. Atlantis rejects keys it doesn't know, so the marker can't be a key of the project
+const unmanagedProjectComment = "# managed: false"
+
+func validatePreserveMatch(strategy string) error {
+	for _, known := range preserveMatchStrategies {
+		if strategy == known {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown preserve match strategy %q, expected one of: %s", strategy, strings.Join(preserveMatchStrategies, ", "))
+}
+
+// Whether the preserved project is marked with a `# managed: false` comment
+func isUnmanaged(project AtlantisProject) bool {
+	return project.unmanaged
+}
+
+// Whether a YAML comment, possibly spanning several lines, holds the `# managed: false` marker
+func hasUnmanagedComment(comment string) bool {
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.TrimSpace(line) == unmanagedProjectComment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Marks the projects of `config` read from `content` that have a `# managed: false` comment, either on the line
+// above the project or at the end of one of its top-level keys
+func markUnmanagedProjects(content []byte, config *AtlantisConfig) error {
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "projects" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for j, item := range root.Content[i+1].Content {
+			if j >= len(config.Projects) {
+				break
+			}
+			marked := hasUnmanagedComment(item.HeadComment) || hasUnmanagedComment(item.LineComment)
+			for _, node := range item.Content {
+				marked = marked || hasUnmanagedComment(node.HeadComment) || hasUnmanagedComment(node.LineComment)
+			}
+			config.Projects[j].unmanaged = marked
+		}
+	}
+
+	return nil
+}
+
+// Writes the `# managed: false` comment above the unmanaged projects of `content`, the YAML output of `config`.
+// Projects are a top-level list, so each of them starts with an unindented `- `
+func commentUnmanagedProjects(content []byte, config *AtlantisConfig) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	output := bytes.Buffer{}
+
+	inProjects := false
+	project := 0
+	for _, line := range lines {
+		switch {
+		case bytes.HasPrefix(line, []byte("projects:")):
+			inProjects = true
+		case inProjects && bytes.HasPrefix(line, []byte("- ")):
+			if project < len(config.Projects) && config.Projects[project].unmanaged {
+				output.WriteString(unmanagedProjectComment + "\n")
+			}
+			project++
+		case len(line) > 0 && line[0] != ' ' && line[0] != '-':
+			inProjects = false
+		}
+		output.Write(line)
+	}
+
+	return output.Bytes()
+}
+
+// Whether the preserved project `old` may be replaced by `generated`, according to `opts.PreserveMatch`. Variants
+// share their dir, so they only ever replace the preserved project with their name
+func (g *generator) preservedProjectCandidate(old AtlantisProject, generated *AtlantisProject) bool {
+	if len(old.configFiles) > 0 || old.Dir != generated.Dir || isUnmanaged(old) {
+		return false
+	}
+	if generated.variant != "" && old.Name != generated.Name {
+		return false
+	}
+
+	return g.opts.PreserveMatch == preserveMatchDir || old.Workspace == generated.Workspace
+}
+
+// Finds the preserved project in `projects` that `generated` replaces, or -1 if there's none. Projects generated in
+// this run know their config files, so a preserved project is only replaced once and the projects sharing a dir
+// each replace their own. The project with the same workspace and name is preferred. Otherwise `dir-workspace-name`
+// falls back to the only project of the dir and workspace, so a name added by hand doesn't lead to a duplicate, while
+// the looser strategies take the first project they match
+func (g *generator) findPreservedProject(log log.Logger, projects []AtlantisProject, generated *AtlantisProject) int {
+	candidates := []int{}
+	for i := range projects {
+		if !g.preservedProjectCandidate(projects[i], generated) {
+			continue
+		}
+		if projects[i].Workspace == generated.Workspace && projects[i].Name == generated.Name {
+			return i
+		}
+		candidates = append(candidates, i)
+	}
+
+	if len(candidates) == 0 {
+		return -1
+	}
+	if g.opts.PreserveMatch != preserveMatchDirWorkspaceName || len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	log.Warnf(
+		"None of the %d preserved projects of %s in the same workspace is named %q, so another project is added next to them",
+		len(candidates), generated.Dir, generated.Name,
+	)
+	return -1
+}
+
+// The execution order group of a project, where projects without one come first
+func executionOrderGroupOf(project AtlantisProject) int {
+	if project.ExecutionOrderGroup == nil {
+		return 0
+	}
+
+	return *project.ExecutionOrderGroup
+}
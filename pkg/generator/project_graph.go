@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"path/filepath"
+)
+
+// A dependency of one project on another
+type projectDependency struct {
+	project *AtlantisProject
+
+	// Absolute path of the resolved dependency making it one, a config file of `project`
+	target string
+}
+
+// Finds the config files of a project. Projects kept from an existing config weren't parsed in this run, so for
+// them the `terragrunt.hcl` in their dir is assumed
+func (g *generator) projectConfigFiles(project *AtlantisProject) []string {
+	if len(project.configFiles) > 0 {
+		return project.configFiles
+	}
+
+	return []string{filepath.Join(g.gitRoot, project.Dir, "terragrunt.hcl")}
+}
+
+// Finds the absolute paths a project depends on. Projects kept from an existing config weren't parsed in this run,
+// so for them the entries of `when_modified` are used
+func (g *generator) projectDependencyPaths(project *AtlantisProject) []string {
+	if len(project.configFiles) > 0 {
+		return project.dependencies
+	}
+
+	paths := []string{}
+	for _, whenModified := range project.Autoplan.WhenModified {
+		paths = append(paths, filepath.Join(g.gitRoot, project.Dir, whenModified))
+	}
+
+	return paths
+}
+
+// Builds the project graph: each project depends on the projects whose config files are among its resolved
+// dependencies, once each and in the order they were resolved. A project depends on the same variant of another
+// project where there is one, and on all its variants otherwise
+func (g *generator) projectDependencies(projects []AtlantisProject) map[*AtlantisProject][]projectDependency {
+	owners := map[string][]*AtlantisProject{}
+	for i := range projects {
+		for _, configFile := range g.projectConfigFiles(&projects[i]) {
+			configFile = filepath.Clean(configFile)
+			owners[configFile] = append(owners[configFile], &projects[i])
+		}
+	}
+
+	dependencies := make(map[*AtlantisProject][]projectDependency, len(projects))
+	for i := range projects {
+		project := &projects[i]
+		seen := map[*AtlantisProject]bool{project: true}
+		for _, path := range g.projectDependencyPaths(project) {
+			target := filepath.Clean(path)
+			for _, depProject := range sameVariantProjects(owners[target], project.variant) {
+				if seen[depProject] {
+					continue
+				}
+				seen[depProject] = true
+				dependencies[project] = append(dependencies[project], projectDependency{project: depProject, target: target})
+			}
+		}
+	}
+
+	return dependencies
+}
+
+// Computes the execution order group of every project as the length of the longest chain of dependencies below it,
+// so a project always comes after everything it depends on. The graph must be free of cycles. Each project and
+// dependency is visited once
+func executionOrderGroups(projects []AtlantisProject, dependencies map[*AtlantisProject][]projectDependency) map[*AtlantisProject]int {
+	groups := make(map[*AtlantisProject]int, len(projects))
+
+	var group func(project *AtlantisProject) int
+	group = func(project *AtlantisProject) int {
+		if projectGroup, ok := groups[project]; ok {
+			return projectGroup
+		}
+
+		projectGroup := 0
+		for _, dependency := range dependencies[project] {
+			if depGroup := group(dependency.project) + 1; depGroup > projectGroup {
+				projectGroup = depGroup
+			}
+		}
+		groups[project] = projectGroup
+
+		return projectGroup
+	}
+
+	for i := range projects {
+		group(&projects[i])
+	}
+
+	return groups
+}
+
+// Drops the dependencies implied by others, keeping the transitive reduction of the project graph. A dependency is
+// implied when the project it's on is also reachable through another dependency. The longest chain below every
+// project stays the same, so the execution order groups don't change. The graph must be free of cycles
+func reduceProjectDependencies(projects []AtlantisProject, dependencies map[*AtlantisProject][]projectDependency) map[*AtlantisProject][]projectDependency {
+	// The projects reachable from each project through at least one dependency
+	reachable := make(map[*AtlantisProject]map[*AtlantisProject]bool, len(projects))
+
+	var reach func(project *AtlantisProject) map[*AtlantisProject]bool
+	reach = func(project *AtlantisProject) map[*AtlantisProject]bool {
+		if reached, ok := reachable[project]; ok {
+			return reached
+		}
+
+		reached := map[*AtlantisProject]bool{}
+		for _, dependency := range dependencies[project] {
+			reached[dependency.project] = true
+			for depProject := range reach(dependency.project) {
+				reached[depProject] = true
+			}
+		}
+		reachable[project] = reached
+
+		return reached
+	}
+
+	reduced := make(map[*AtlantisProject][]projectDependency, len(projects))
+	for i := range projects {
+		project := &projects[i]
+		for _, dependency := range dependencies[project] {
+			implied := false
+			for _, other := range dependencies[project] {
+				if other.project != dependency.project && reach(other.project)[dependency.project] {
+					implied = true
+					break
+				}
+			}
+			if !implied {
+				reduced[project] = append(reduced[project], dependency)
+			}
+		}
+	}
+
+	return reduced
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// Drops the projects kept from the existing config that nothing generates any more: those whose dir has no
+// terragrunt config left, and those whose unit is skipped now. With `opts.PruneDryRun` they're only listed
+func (g *generator) prunePreservedProjects(ctx context.Context, log log.Logger, config *AtlantisConfig) {
+	projects := []AtlantisProject{}
+	for _, project := range config.Projects {
+		// Projects generated in this run know the configs they were generated from, and handwritten ones are never
+		// removed
+		if len(project.configFiles) > 0 || isUnmanaged(project) {
+			projects = append(projects, project)
+			continue
+		}
+
+		reason := g.pruneReason(ctx, log, project)
+		if reason == "" {
+			projects = append(projects, project)
+			continue
+		}
+
+		name := project.Dir
+		if project.Name != "" {
+			name = fmt.Sprintf("%s (%s)", project.Name, project.Dir)
+		}
+		if g.opts.PruneDryRun {
+			log.Infof("Would remove preserved project %s, as %s", name, reason)
+			projects = append(projects, project)
+		} else {
+			log.Infof("Removed preserved project %s, as %s", name, reason)
+		}
+	}
+	config.Projects = projects
+}
+
+// Explains why a preserved project is pruned, or returns "" when it's kept
+func (g *generator) pruneReason(ctx context.Context, log log.Logger, project AtlantisProject) string {
+	dir := filepath.Join(g.gitRoot, filepath.FromSlash(project.Dir))
+
+	configPath := ""
+	for _, name := range append([]string{"terragrunt.hcl", "terragrunt.hcl.json", "terragrunt.stack.hcl"}, g.opts.ProjectHclFiles...) {
+		if path := filepath.Join(dir, name); util.FileExists(path) && !util.IsDir(path) {
+			configPath = path
+			break
+		}
+	}
+	if configPath == "" {
+		return fmt.Sprintf("no terragrunt config is left in %s", project.Dir)
+	}
+	if !strings.HasPrefix(filepath.Base(configPath), "terragrunt.") {
+		return ""
+	}
+
+	// Units that fail to parse are left alone, as whether they're still wanted can't be told
+	unit, err := g.getDirectDependencies(ctx, log, configPath)
+	if err != nil {
+		log.Warnf("Keeping preserved project %s, as %s can't be parsed: %v", project.Dir, configPath, err)
+		return ""
+	}
+	if unit.excluded {
+		return fmt.Sprintf("the exclude block of %s applies to %s", g.nodeID(configPath), strings.Join(atlantisActions, " or "))
+	}
+	if unit.locals.Skip != nil && *unit.locals.Skip {
+		return fmt.Sprintf("%s sets atlantis_skip", g.nodeID(configPath))
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Builds an error for a repo-level setting passed in the options that differs from the one in the old config
+func repoSettingConflict(key string, value interface{}, oldValue interface{}, outputPath string) error {
+	return fmt.Errorf(
+		"%s is set to %v, but %s already sets it to %v. Either drop the setting or remove the key from %s",
+		key, value, outputPath, oldValue, outputPath,
+	)
+}
+
+// Fills in the repo-level settings of `config`. Settings from the options take precedence over the defaults,
+// while the ones this library doesn't generate on its own are preserved from `oldConfig`. An option that
+// contradicts a preserved setting is an error, as it's unclear which one is meant to win
+func (g *generator) applyRepoSettings(config *AtlantisConfig, oldConfig *AtlantisConfig) error {
+	config.ParallelPlan = g.opts.Parallel
+	if g.opts.ParallelPlan != nil {
+		config.ParallelPlan = *g.opts.ParallelPlan
+	}
+
+	config.ParallelApply = g.opts.Parallel
+	if g.opts.ParallelApply != nil {
+		config.ParallelApply = *g.opts.ParallelApply
+	}
+
+	if oldConfig == nil {
+		oldConfig = &AtlantisConfig{}
+	}
+	config.AbortOnExecutionOrderFail = oldConfig.AbortOnExecutionOrderFail
+	config.DeleteSourceBranchOnMerge = oldConfig.DeleteSourceBranchOnMerge
+	config.AllowedRegexpPrefixes = oldConfig.AllowedRegexpPrefixes
+	config.Autodiscover = oldConfig.Autodiscover
+
+	if g.opts.AbortOnExecutionOrderFail != nil {
+		if oldConfig.AbortOnExecutionOrderFail != nil && *oldConfig.AbortOnExecutionOrderFail != *g.opts.AbortOnExecutionOrderFail {
+			return repoSettingConflict("abort_on_execution_order_fail", *g.opts.AbortOnExecutionOrderFail, *oldConfig.AbortOnExecutionOrderFail, g.opts.OutputPath)
+		}
+		config.AbortOnExecutionOrderFail = g.opts.AbortOnExecutionOrderFail
+	}
+
+	if g.opts.DeleteSourceBranchOnMerge != nil {
+		if oldConfig.DeleteSourceBranchOnMerge != nil && *oldConfig.DeleteSourceBranchOnMerge != *g.opts.DeleteSourceBranchOnMerge {
+			return repoSettingConflict("delete_source_branch_on_merge", *g.opts.DeleteSourceBranchOnMerge, *oldConfig.DeleteSourceBranchOnMerge, g.opts.OutputPath)
+		}
+		config.DeleteSourceBranchOnMerge = g.opts.DeleteSourceBranchOnMerge
+	}
+
+	if g.opts.AllowedRegexpPrefixes != nil {
+		if oldConfig.AllowedRegexpPrefixes != nil && !reflect.DeepEqual(oldConfig.AllowedRegexpPrefixes, g.opts.AllowedRegexpPrefixes) {
+			return repoSettingConflict(
+				"allowed_regexp_prefixes",
+				"["+strings.Join(g.opts.AllowedRegexpPrefixes, ", ")+"]",
+				"["+strings.Join(oldConfig.AllowedRegexpPrefixes, ", ")+"]",
+				g.opts.OutputPath,
+			)
+		}
+		config.AllowedRegexpPrefixes = g.opts.AllowedRegexpPrefixes
+	}
+
+	if g.opts.Autodiscover != "" {
+		if g.opts.Autodiscover != "auto" && g.opts.Autodiscover != "enabled" && g.opts.Autodiscover != "disabled" {
+			return fmt.Errorf("unknown autodiscover mode %q, expected one of: auto, enabled, disabled", g.opts.Autodiscover)
+		}
+		if oldConfig.Autodiscover != nil && oldConfig.Autodiscover.Mode != g.opts.Autodiscover {
+			return repoSettingConflict("autodiscover mode", g.opts.Autodiscover, oldConfig.Autodiscover.Mode, g.opts.OutputPath)
+		}
+		if config.Autodiscover == nil {
+			config.Autodiscover = &AutodiscoverConfig{}
+		}
+		config.Autodiscover.Mode = g.opts.Autodiscover
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/moby/patternmatcher"
+)
+
+// Settings assigned to every project whose dir matches a glob, before the locals of the project are applied
+type rule struct {
+	// The rule as it was written, for logging
+	raw string
+
+	// Matches the project dir, relative to the git root
+	matcher *patternmatcher.PatternMatcher
+
+	workflow            *string
+	applyRequirements   *[]string
+	planRequirements    *[]string
+	autoPlan            *bool
+	terraformVersion    *string
+	executionOrderGroup *int
+}
+
+// Splits a comma-separated list. An empty value is an empty list
+func splitRuleList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// Parses a rule in the format `GLOB:KEY=VALUE;KEY=VALUE`, e.g. `prod/**:workflow=prod;apply_requirements=approved,mergeable`
+func parseRule(raw string) (*rule, error) {
+	glob, settings, found := strings.Cut(raw, ":")
+	glob = strings.TrimSpace(glob)
+	if !found || glob == "" || strings.TrimSpace(settings) == "" {
+		return nil, fmt.Errorf("invalid rule %q, expected GLOB:KEY=VALUE;KEY=VALUE", raw)
+	}
+
+	matcher, err := patternmatcher.New([]string{glob})
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob in rule %q: %w", raw, err)
+	}
+	parsed := &rule{raw: raw, matcher: matcher}
+
+	for _, setting := range strings.Split(settings, ";") {
+		key, value, found := strings.Cut(setting, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !found {
+			return nil, fmt.Errorf("invalid setting %q in rule %q, expected KEY=VALUE", setting, raw)
+		}
+
+		switch key {
+		case "workflow":
+			parsed.workflow = &value
+		case "apply_requirements":
+			list := splitRuleList(value)
+			parsed.applyRequirements = &list
+		case "plan_requirements":
+			list := splitRuleList(value)
+			parsed.planRequirements = &list
+		case "autoplan":
+			autoPlan, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid autoplan value %q in rule %q", value, raw)
+			}
+			parsed.autoPlan = &autoPlan
+		case "terraform_version":
+			parsed.terraformVersion = &value
+		case "execution_order_group":
+			group, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid execution_order_group value %q in rule %q", value, raw)
+			}
+			parsed.executionOrderGroup = &group
+		default:
+			return nil, fmt.Errorf(
+				"unknown key %q in rule %q, expected one of: workflow, apply_requirements, plan_requirements, autoplan, terraform_version, execution_order_group",
+				key, raw,
+			)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Parses all rules, keeping their order
+func parseRules(rawRules []string) ([]*rule, error) {
+	rules := []*rule{}
+	for _, raw := range rawRules {
+		parsed, err := parseRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed)
+	}
+
+	return rules, nil
+}
+
+// Applies every rule matching the dir of the project in order, so later rules win over earlier ones
+func (g *generator) applyRules(log log.Logger, project *AtlantisProject) error {
+	for _, rule := range g.rules {
+		match, err := rule.matcher.MatchesOrParentMatches(project.Dir)
+		if err != nil {
+			return err
+		}
+		if !match {
+			continue
+		}
+
+		log.Debugf("Rule %q matched project %s", rule.raw, project.Dir)
+
+		if rule.workflow != nil {
+			project.Workflow = *rule.workflow
+		}
+		if rule.applyRequirements != nil {
+			project.ApplyRequirements = rule.applyRequirements
+		}
+		if rule.planRequirements != nil {
+			project.PlanRequirements = rule.planRequirements
+		}
+		if rule.autoPlan != nil {
+			project.Autoplan.Enabled = *rule.autoPlan
+		}
+		if rule.terraformVersion != nil {
+			project.TerraformVersion = *rule.terraformVersion
+		}
+		if rule.executionOrderGroup != nil {
+			group := *rule.executionOrderGroup
+			project.ExecutionOrderGroup = &group
+		}
+	}
+
+	return nil
+}
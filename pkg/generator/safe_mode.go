@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Functions that run commands or call out to a cloud provider, and so are stubbed in safe mode
+var unsafeFunctions = []string{
+	config.FuncNameRunCmd,
+	config.FuncNameGetAWSAccountAlias,
+	config.FuncNameGetAWSAccountID,
+	config.FuncNameGetAWSCallerIdentityArn,
+	config.FuncNameGetAWSCallerIdentityUserID,
+	config.FuncNameSopsDecryptFile,
+}
+
+// A local whose value is unknown, because it depends on a function stubbed in safe mode
+type unknownLocal struct {
+	// Name of the local, without the `local.` prefix
+	name string
+
+	// Absolute path of the file declaring the local
+	path string
+}
+
+// Parses fixtures in the format `FUNCTION=VALUE`, e.g. `get_aws_account_id=123456789012`
+func parseSafeModeFixtures(rawFixtures []string) (map[string]string, error) {
+	fixtures := map[string]string{}
+	for _, raw := range rawFixtures {
+		name, value, found := strings.Cut(raw, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid safe mode fixture %q, expected FUNCTION=VALUE", raw)
+		}
+		if !isUnsafeFunction(name) {
+			return nil, fmt.Errorf(
+				"invalid safe mode fixture %q, %s isn't stubbed in safe mode. Expected one of: %s",
+				raw, name, strings.Join(unsafeFunctions, ", "),
+			)
+		}
+		fixtures[name] = value
+	}
+
+	return fixtures, nil
+}
+
+func isUnsafeFunction(name string) bool {
+	for _, unsafeFunction := range unsafeFunctions {
+		if unsafeFunction == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Builds the stubs replacing the unsafe functions. A stub returns the fixture of its function, or else an unknown
+// string, so whatever is computed from it becomes unknown too
+func safeModeFunctions(fixtures map[string]string) map[string]function.Function {
+	functions := map[string]function.Function{}
+	for _, name := range unsafeFunctions {
+		result := cty.UnknownVal(cty.String)
+		if fixture, ok := fixtures[name]; ok {
+			result = cty.StringVal(fixture)
+		}
+
+		functions[name] = function.New(&function.Spec{
+			VarParam: &function.Parameter{
+				Name:             "args",
+				Type:             cty.DynamicPseudoType,
+				AllowUnknown:     true,
+				AllowNull:        true,
+				AllowDynamicType: true,
+			},
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				return result, nil
+			},
+		})
+	}
+
+	return functions
+}
+
+// Replaces the unsafe functions of a parsing context by their stubs. Nothing is replaced when `stubs` is empty, i.e.
+// outside of safe mode. The file passed to `sops_decrypt_file` is still tracked, as the module depends on it
+func withStubbedFunctions(parsingContext *config.ParsingContext, stubs map[string]function.Function) *config.ParsingContext {
+	if len(stubs) == 0 {
+		return parsingContext
+	}
+
+	baseDir := filepath.Dir(parsingContext.TerragruntOptions.TerragruntConfigPath)
+	functions := map[string]function.Function{}
+	for name, predefined := range parsingContext.PredefinedFunctions {
+		functions[name] = predefined
+	}
+	for name, stub := range stubs {
+		functions[name] = stub
+	}
+	if stub, ok := stubs[config.FuncNameSopsDecryptFile]; ok {
+		functions[config.FuncNameSopsDecryptFile] = trackFileArgument(stub, baseDir, parsingContext.FilesRead)
+	}
+	parsingContext.PredefinedFunctions = functions
+
+	return parsingContext
+}
+
+// Collects the locals whose value isn't known, sorted by name
+func findUnknownLocals(path string, rawLocals map[string]cty.Value) []unknownLocal {
+	unknown := []unknownLocal{}
+	for name, value := range rawLocals {
+		if !value.IsWhollyKnown() {
+			unknown = append(unknown, unknownLocal{name: name, path: path})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].name < unknown[j].name
+	})
+
+	return unknown
+}
+
+// Warns about the locals of a project that safe mode left unknown, as any setting computed from them was ignored
+func (g *generator) reportUnknownLocals(log log.Logger, projectPath string, locals ResolvedLocals) {
+	if len(locals.unknownLocals) == 0 {
+		return
+	}
+
+	names := []string{}
+	for _, local := range locals.unknownLocals {
+		relativePath, err := filepath.Rel(g.gitRoot, local.path)
+		if err != nil {
+			relativePath = local.path
+		}
+		names = append(names, fmt.Sprintf("local.%s (%s)", local.name, filepath.ToSlash(relativePath)))
+	}
+
+	log.Warnf(
+		"Safe mode left these locals of %s unknown, settings computed from them are ignored: %s",
+		projectPath, strings.Join(names, ", "),
+	)
+}
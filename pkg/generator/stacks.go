@@ -0,0 +1,317 @@
+package generator
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/go-getter"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Something a stack file generates below `.terragrunt-stack`, resolved without generating it
+type stackComponent struct {
+	// Absolute path of the dir the component is generated to
+	dir string
+
+	// Absolute path of the local dir the component is copied from. Empty for remote sources
+	sourceDir string
+
+	// Absolute paths of the stack files generating the component, from the outermost, and of the files read
+	// while evaluating them
+	files []string
+}
+
+// Everything a stack file generates, including what its nested stacks generate
+type stackExpansion struct {
+	units []stackComponent
+
+	// Nested stacks with a remote source, whose units can't be known without downloading them
+	remoteStacks []stackComponent
+
+	// Absolute paths of the local dirs units and nested stacks are copied from
+	catalogDirs []string
+}
+
+// Resolves the source of a unit or stack to a local dir, relative to the dir of the stack file generating it.
+// Returns "" for remote sources
+func localStackSource(source string, stackDir string) (string, error) {
+	detected, err := getter.Detect(source, stackDir, getter.Detectors)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(detected, "file://") {
+		return "", nil
+	}
+
+	return filepath.Clean(strings.TrimPrefix(detected, "file://")), nil
+}
+
+// Finds the dir a unit or stack named `path` is generated to by the stack file in `stackDir`
+func generatedStackDir(stackDir string, path string, noStack *bool) string {
+	if noStack != nil && *noStack {
+		return filepath.Join(stackDir, path)
+	}
+
+	return filepath.Join(stackDir, config.StackDir, path)
+}
+
+// Resolves the units the stack file at `stackFile` generates, following nested stacks with a local source
+func (g *generator) expandStack(ctx context.Context, log log.Logger, stackFile string) (*stackExpansion, error) {
+	parsingContext, err := g.newParsingContext(ctx, log, stackFile)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	valuesFile := filepath.Join(filepath.Dir(stackFile), "terragrunt.values.hcl")
+	if util.FileExists(valuesFile) {
+		files = append(files, valuesFile)
+	}
+	values, err := parsingContext.readValues(log, filepath.Dir(stackFile))
+	if err != nil {
+		return nil, err
+	}
+
+	expansion := &stackExpansion{}
+	err = g.expandStackFile(ctx, log, stackFile, stackFile, values, files, expansion)
+	if err != nil {
+		return nil, err
+	}
+
+	return expansion, nil
+}
+
+// Reads the stack file at `sourcePath` as if it was at `configPath`, which is where a nested stack is generated
+// to, and adds what it generates to `expansion`
+func (g *generator) expandStackFile(
+	ctx context.Context,
+	log log.Logger,
+	sourcePath string,
+	configPath string,
+	values *cty.Value,
+	parentFiles []string,
+	expansion *stackExpansion,
+) error {
+	parsingContext, err := g.newParsingContext(ctx, log, configPath)
+	if err != nil {
+		return err
+	}
+
+	content, err := util.ReadFileAsString(sourcePath)
+	if err != nil {
+		return err
+	}
+	file, err := hclparse.NewParser(parsingContext.ParsingContext.ParserOptions...).ParseFromString(content, configPath)
+	if err != nil {
+		return err
+	}
+	stackConfig, err := config.ParseStackConfig(log, parsingContext.ParsingContext, parsingContext.ParsingContext.TerragruntOptions, file, values)
+	if err != nil {
+		return err
+	}
+
+	files := append([]string{}, parentFiles...)
+	files = append(files, sourcePath)
+	readFiles := []string{}
+	for _, readFile := range *parsingContext.ParsingContext.FilesRead {
+		readFiles = append(readFiles, filepath.Clean(readFile))
+	}
+	sort.Strings(readFiles)
+	files = uniqueStrings(append(files, readFiles...))
+
+	stackDir := filepath.Dir(configPath)
+	for _, unit := range stackConfig.Units {
+		sourceDir, err := localStackSource(unit.Source, stackDir)
+		if err != nil {
+			return err
+		}
+		if sourceDir != "" {
+			expansion.catalogDirs = append(expansion.catalogDirs, sourceDir)
+		}
+
+		expansion.units = append(expansion.units, stackComponent{
+			dir:       generatedStackDir(stackDir, unit.Path, unit.NoStack),
+			sourceDir: sourceDir,
+			files:     files,
+		})
+	}
+
+	for _, stack := range stackConfig.Stacks {
+		dir := generatedStackDir(stackDir, stack.Path, stack.NoStack)
+		sourceDir, err := localStackSource(stack.Source, stackDir)
+		if err != nil {
+			return err
+		}
+		if sourceDir == "" {
+			log.Warnf("Can't expand stack %s of %s, as its source %s isn't local", stack.Name, sourcePath, stack.Source)
+			expansion.remoteStacks = append(expansion.remoteStacks, stackComponent{dir: dir, files: files})
+			continue
+		}
+		expansion.catalogDirs = append(expansion.catalogDirs, sourceDir)
+
+		err = g.expandStackFile(
+			ctx, log,
+			filepath.Join(sourceDir, config.DefaultStackFile),
+			filepath.Join(dir, config.DefaultStackFile),
+			stack.Values, files, expansion,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finds the dirs of the units and stacks that the stack files below the git root copy from. With
+// `opts.ExpandStacks` these are catalogs, which only make sense once generated, so they don't get projects
+func (g *generator) findStackCatalogDirs(ctx context.Context, log log.Logger) (map[string]bool, error) {
+	catalogDirs := map[string]bool{}
+	if !g.opts.ExpandStacks {
+		return catalogDirs, nil
+	}
+
+	configFiles, err := g.getAllTerragruntFiles(g.gitRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stacks in a catalog may only evaluate once generated, e.g. when they use `values`, so their errors only count
+	// when they turn out not to be in a catalog. They're reported through the returned error, and the warnings again
+	// when creating the projects, so nothing is logged here
+	quietLog := discardLogs(log)
+	stackErrors := map[string]error{}
+	stackFiles := []string{}
+	for _, configFile := range configFiles {
+		if filepath.Base(configFile) != config.DefaultStackFile || isGeneratedByStack(configFile) {
+			continue
+		}
+		stackFiles = append(stackFiles, configFile)
+
+		expansion, err := g.expandStack(ctx, quietLog, configFile)
+		if err != nil {
+			stackErrors[configFile] = err
+			continue
+		}
+		for _, catalogDir := range expansion.catalogDirs {
+			catalogDirs[catalogDir] = true
+		}
+	}
+
+	for _, stackFile := range stackFiles {
+		if err, ok := stackErrors[stackFile]; ok && !isInCatalog(stackFile, catalogDirs) {
+			return nil, err
+		}
+	}
+
+	return catalogDirs, nil
+}
+
+// Creates a logger formatting like `logger` that drops everything. Deriving it with `WithOptions` isn't safe while
+// other goroutines log
+func discardLogs(logger log.Logger) log.Logger {
+	return log.New(log.WithOutput(io.Discard), log.WithFormatter(logger.Formatter()))
+}
+
+// Whether a config file is in one of the catalog dirs found by `findStackCatalogDirs`
+func isInCatalog(configFile string, catalogDirs map[string]bool) bool {
+	for catalogDir := range catalogDirs {
+		if strings.HasPrefix(configFile, catalogDir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether a config file was generated into `.terragrunt-stack` by `terragrunt stack generate`
+func isGeneratedByStack(configFile string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(configFile)), "/") {
+		if part == config.StackDir {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Creates a project for each unit a stack file generates, and for each nested stack that can't be expanded.
+// Each depends on the stack files generating it, the files read while evaluating their values, and the catalog
+// dir it's copied from. The locals of the stack file apply to all of them
+func (g *generator) createStackProjects(ctx context.Context, log log.Logger, stackFile string, locals ResolvedLocals) ([]*AtlantisProject, error) {
+	expansion, err := g.expandStack(ctx, log, stackFile)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []*AtlantisProject{}
+	for _, component := range append(expansion.units, expansion.remoteStacks...) {
+		whenModified := []string{}
+		dependencies := []string{}
+		configFile := filepath.Join(component.dir, "terragrunt.hcl")
+		if component.sourceDir != "" {
+			// The unit itself is generated, so only what it's generated from can change
+			whenModified = append(whenModified, filepath.Join(component.sourceDir, "**"))
+			dependencies = append(dependencies, filepath.Join(component.sourceDir, "**"))
+		} else {
+			whenModified = append(whenModified, "*.hcl", "*.tf*")
+			configFile = filepath.Join(component.dir, config.DefaultStackFile)
+		}
+		whenModified = append(whenModified, component.files...)
+		dependencies = append(dependencies, component.files...)
+
+		for i, path := range whenModified {
+			if !filepath.IsAbs(path) {
+				continue
+			}
+			relativePath, err := filepath.Rel(component.dir, path)
+			if err != nil {
+				return nil, err
+			}
+			whenModified[i] = filepath.ToSlash(relativePath)
+		}
+
+		g.recordEdges(stackFile, EdgeRead, component.files...)
+		if component.sourceDir != "" {
+			g.recordEdges(stackFile, EdgeModuleSource, filepath.Join(component.sourceDir, "**"))
+		}
+
+		relativeDir, err := filepath.Rel(g.gitRoot, component.dir)
+		if err != nil {
+			return nil, err
+		}
+
+		project := g.newProject(relativeDir, whenModified)
+		project.configFiles = []string{configFile}
+		project.dependencies = dependencies
+		err = g.applyRules(log, project)
+		if err != nil {
+			return nil, err
+		}
+		applyProjectLocals(project, locals)
+
+		projectName := invalidNameCharacters.ReplaceAllString(project.Dir, "_")
+		if g.opts.CreateProjectName {
+			project.Name = projectName
+		}
+		if g.opts.CreateWorkspace {
+			project.Workspace = projectName
+		}
+		g.nameVariantProject(project, projectName)
+
+		project, err = g.applyProjectOverrides(project, locals.ProjectOverrides, stackFile)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,317 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	_ "unsafe"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+type parsedHcl struct {
+	Terraform *config.TerraformConfig `hcl:"terraform,block"`
+	Includes  []config.IncludeConfig  `hcl:"include,block"`
+}
+
+// terragruntIncludeMultiple is a struct that can be used to only decode the include block with labels.
+type terragruntIncludeMultiple struct {
+	Include []config.IncludeConfig `hcl:"include,block"`
+	Remain  hcl.Body               `hcl:",remain"`
+}
+
+type TerragruntParsingContext struct {
+	context.Context
+
+	ParsingContext *config.ParsingContext
+
+	// Functions replacing terragrunt's own, passed on to every context derived from this one: the stubs of the
+	// unsafe functions in safe mode, and the `get_env` tracking the variables read for the parse cache
+	stubbedFunctions map[string]function.Function
+}
+
+// Wraps the `file` and `templatefile` functions, which come from terraform and so aren't tracked by terragrunt, to
+// track the files they read in `FilesRead` just like the file reading functions of terragrunt do. The wrapped
+// functions are the ones terragrunt registers for the config, so relative paths are resolved from its directory
+func withTrackedFileFunctions(log log.Logger, parsingContext *config.ParsingContext) (*config.ParsingContext, error) {
+	configPath := parsingContext.TerragruntOptions.TerragruntConfigPath
+	evalContext, err := createTerragruntEvalContext(parsingContext, log, configPath)
+	if err != nil {
+		return nil, err
+	}
+	baseDir := filepath.Dir(configPath)
+	filesRead := parsingContext.FilesRead
+
+	functions := map[string]function.Function{}
+	for name, predefined := range parsingContext.PredefinedFunctions {
+		functions[name] = predefined
+	}
+	for _, name := range []string{"file", "templatefile"} {
+		functions[name] = trackFileArgument(evalContext.Functions[name], baseDir, filesRead)
+	}
+	parsingContext.PredefinedFunctions = functions
+
+	return parsingContext, nil
+}
+
+// Wraps a function whose first argument is a path, adding that path to `filesRead` whenever it's called
+func trackFileArgument(original function.Function, baseDir string, filesRead *[]string) function.Function {
+	return function.New(&function.Spec{
+		Params:   original.Params(),
+		VarParam: original.VarParam(),
+		Type: func(args []cty.Value) (cty.Type, error) {
+			return original.ReturnTypeForValues(args)
+		},
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if filesRead != nil && len(args) > 0 && args[0].IsKnown() && !args[0].IsNull() && args[0].Type() == cty.String {
+				path := args[0].AsString()
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(baseDir, path)
+				}
+				path = filepath.Clean(path)
+				if !slices.Contains(*filesRead, path) {
+					*filesRead = append(*filesRead, path)
+				}
+			}
+
+			return original.Call(args)
+		},
+	})
+}
+
+// NewParsingContextWithConfigPath creates the context for parsing the config at `terragruntConfigPath`, where
+// `get_env` sees exactly the variables of `env`
+func NewParsingContextWithConfigPath(ctx context.Context, log log.Logger, terragruntConfigPath string, env map[string]string) (*TerragruntParsingContext, error) {
+	opt, err := options.NewTerragruntOptionsWithConfigPath(terragruntConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	opt.OriginalTerragruntConfigPath = terragruntConfigPath
+	opt.Env = env
+
+	parsingContext, err := withTrackedFileFunctions(log, config.NewParsingContext(ctx, log, opt))
+	if err != nil {
+		return nil, err
+	}
+
+	terragruntParsingContext := TerragruntParsingContext{
+		Context:        ctx,
+		ParsingContext: parsingContext,
+	}
+
+	return &terragruntParsingContext, nil
+}
+
+func NewParsingContextWithDecodeList(ctx *TerragruntParsingContext, log log.Logger) (*TerragruntParsingContext, error) {
+	// Parse the HCL file
+	parseCtx, err := withTrackedFileFunctions(log, config.NewParsingContext(ctx.ParsingContext, log, ctx.ParsingContext.TerragruntOptions))
+	if err != nil {
+		return nil, err
+	}
+	parseCtx = withStubbedFunctions(parseCtx, ctx.stubbedFunctions).
+		WithDecodeList(
+			config.DependencyBlock,
+			config.TerraformBlock,
+			config.FeatureFlagsBlock,
+			config.ExcludeBlock,
+		)
+
+	terragruntParsingContext := TerragruntParsingContext{
+		Context:          ctx.Context,
+		ParsingContext:   parseCtx,
+		stubbedFunctions: ctx.stubbedFunctions,
+	}
+
+	return &terragruntParsingContext, nil
+}
+
+// WithStubbedFunctions replaces functions of this context, and of all contexts derived from it, by `stubs`
+func (ctx TerragruntParsingContext) WithStubbedFunctions(stubs map[string]function.Function) *TerragruntParsingContext {
+	ctx.ParsingContext = withStubbedFunctions(ctx.ParsingContext, stubs)
+	ctx.stubbedFunctions = stubs
+
+	return &ctx
+}
+
+func (ctx TerragruntParsingContext) WithDecodedList() *TerragruntParsingContext {
+	ctx.ParsingContext.WithDecodeList(
+		config.DependencyBlock,
+		config.DependenciesBlock,
+		config.TerraformBlock,
+	)
+
+	return &ctx
+}
+
+func (ctx TerragruntParsingContext) WithTerragruntOptions(opts *options.TerragruntOptions) *TerragruntParsingContext {
+	ctx.ParsingContext.WithTerragruntOptions(opts)
+
+	return &ctx
+}
+
+// Partially parses an already parsed config file, decoding the blocks of the decode list of this context
+func (ctx TerragruntParsingContext) partialParseFile(log log.Logger, file *hclparse.File) (*config.TerragruntConfig, error) {
+	return config.TerragruntConfigFromPartialConfig(ctx.ParsingContext, log, file, nil)
+}
+
+// DecodeBaseBlocks Decode just the Base blocks. See the function docs for DecodeBaseBlocks for more info on what base blocks are.
+func (ctx TerragruntParsingContext) DecodeBaseBlocks(log log.Logger, path string, includeFromChild *config.IncludeConfig) (*config.DecodedBaseBlocks, error) {
+	file, err := hclparse.NewParser(ctx.ParsingContext.ParserOptions...).ParseFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.decodeBaseBlocksOfFile(log, file, includeFromChild)
+}
+
+// Decodes the base blocks of an already parsed config file, see `DecodeBaseBlocks`
+func (ctx TerragruntParsingContext) decodeBaseBlocksOfFile(log log.Logger, file *hclparse.File, includeFromChild *config.IncludeConfig) (*config.DecodedBaseBlocks, error) {
+	parsingContext := ctx.ParsingContext.
+		WithDecodeList(config.DependencyBlock, config.DependenciesBlock, config.TerraformBlock)
+
+	// Mirror Terragrunt parsing flow: load terragrunt.values.hcl and expose it as `values`.
+	unitValues, err := ctx.readValues(log, filepath.Dir(file.ConfigPath))
+	if err != nil {
+		return nil, err
+	}
+	parsingContext = parsingContext.WithValues(unitValues)
+
+	return config.DecodeBaseBlocks(parsingContext, log, file, includeFromChild)
+}
+
+// Reads the terragrunt.values.hcl file in `directory` the same way `config.ReadValues` does, but with the functions
+// of this context, so reading files is tracked and unsafe functions stay stubbed
+func (ctx TerragruntParsingContext) readValues(log log.Logger, directory string) (*cty.Value, error) {
+	filePath := filepath.Join(directory, "terragrunt.values.hcl")
+	if util.FileNotExists(filePath) {
+		return nil, nil
+	}
+
+	log.Debugf("Reading Terragrunt stack values file at %s", filePath)
+	parser, err := withTrackedFileFunctions(log, config.NewParsingContext(ctx.Context, log, ctx.ParsingContext.TerragruntOptions))
+	if err != nil {
+		return nil, err
+	}
+	parser = withStubbedFunctions(parser, ctx.stubbedFunctions)
+
+	file, err := hclparse.NewParser(parser.ParserOptions...).ParseFromFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	evalContext, err := createTerragruntEvalContext(parser, log, file.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]cty.Value{}
+	if err := file.Decode(&values, evalContext); err != nil {
+		return nil, err
+	}
+
+	result := cty.ObjectVal(values)
+	return &result, nil
+}
+
+// FindConfigFilesInPath returns a list of all Terragrunt config files in the given path or any subfolder of the path. A file is a Terragrunt
+// config file if it has a name as returned by the DefaultConfigPath method
+func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
+	configFiles := []string{}
+
+	walkFunc := filepath.Walk
+
+	err := walkFunc(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		for _, configFile := range []string{"root.hcl", "terragrunt.stack.hcl"} {
+			if !filepath.IsAbs(configFile) {
+				configFile = filepath.Join(path, configFile)
+			}
+
+			if !util.IsDir(configFile) && util.FileExists(configFile) {
+				configFiles = append(configFiles, configFile)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	nestedConfigFiles, err := config.FindConfigFilesInPath(rootPath, opts)
+	if err == nil {
+		configFiles = append(configFiles, nestedConfigFiles...)
+	}
+	return configFiles, nil
+}
+
+// Finds the absolute paths of all terragrunt.hcl files
+func (g *generator) getAllTerragruntFiles(path string) ([]string, error) {
+	terragruntOptions, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// If filterPaths is provided, override workingPath instead of gitRoot
+	// We do this here because we want to keep the relative path structure of Terragrunt files
+	// to root and just ignore the ConfigFiles
+	workingPaths := []string{path}
+
+	// filters are not working (yet) if using project hcl files (which are kind of filters by themselves)
+	if len(g.opts.FilterPaths) > 0 && len(g.opts.ProjectHclFiles) == 0 {
+		workingPaths = []string{}
+		for _, filterPath := range g.opts.FilterPaths {
+			// get all matching folders
+			theseWorkingPaths, err := filepath.Glob(filterPath)
+			if err != nil {
+				return nil, err
+			}
+			workingPaths = append(workingPaths, theseWorkingPaths...)
+		}
+	}
+
+	uniqueConfigFilePaths := make(map[string]bool)
+	orderedConfigFilePaths := []string{}
+	for _, workingPath := range workingPaths {
+		paths, err := FindConfigFilesInPath(workingPath, terragruntOptions)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			// if path not yet seen, insert once
+			if !uniqueConfigFilePaths[p] {
+				orderedConfigFilePaths = append(orderedConfigFilePaths, p)
+				uniqueConfigFilePaths[p] = true
+			}
+		}
+	}
+
+	uniqueConfigFileAbsPaths := []string{}
+	for _, uniquePath := range orderedConfigFilePaths {
+		uniqueAbsPath, err := filepath.Abs(uniquePath)
+		if err != nil {
+			return nil, err
+		}
+		uniqueConfigFileAbsPaths = append(uniqueConfigFileAbsPaths, uniqueAbsPath)
+	}
+
+	return uniqueConfigFileAbsPaths, nil
+}
+
+//go:linkname createTerragruntEvalContext github.com/gruntwork-io/terragrunt/config.createTerragruntEvalContext
+func createTerragruntEvalContext(ctx *config.ParsingContext, l log.Logger, configPath string) (*hcl.EvalContext, error)
+
+//go:linkname getEnvironmentVariable github.com/gruntwork-io/terragrunt/config.getEnvironmentVariable
+func getEnvironmentVariable(ctx *config.ParsingContext, l log.Logger, parameters []string) (string, error)
@@ -0,0 +1,171 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The environment variable terraform selects its workspace from, set while evaluating a workspace variant
+const workspaceEnvVariable = "TF_WORKSPACE"
+
+// Characters that aren't allowed in project names and workspaces
+var invalidNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// One evaluation of every unit, with some variables added to the environment `get_env` sees
+type variant struct {
+	// Distinguishes the projects of this variant from those of the other variants, e.g. `prod` or `prod-blue`
+	name string
+
+	// Variables added to the environment while parsing
+	env map[string]string
+
+	// The terraform workspace of the projects. When empty, the name of the variant is used
+	workspace string
+}
+
+// A single axis of the matrix, in the format `KEY=v1,v2`
+type matrixAxis struct {
+	key    string
+	values []string
+}
+
+// Parses the axes of the matrix, keeping their order
+func parseMatrix(rawAxes []string) ([]matrixAxis, error) {
+	axes := []matrixAxis{}
+	seen := map[string]bool{}
+	for _, raw := range rawAxes {
+		key, rawValues, found := strings.Cut(raw, "=")
+		key = strings.TrimSpace(key)
+		values := splitRuleList(rawValues)
+		if !found || key == "" || len(values) == 0 {
+			return nil, fmt.Errorf("invalid matrix %q, expected KEY=VALUE,VALUE", raw)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("matrix key %s is set more than once", key)
+		}
+		seen[key] = true
+
+		axes = append(axes, matrixAxis{key: key, values: values})
+	}
+
+	return axes, nil
+}
+
+// Builds one variant for every combination of the values of the axes, in order. Without axes there are no variants
+func matrixVariants(axes []matrixAxis) []*variant {
+	if len(axes) == 0 {
+		return nil
+	}
+
+	variants := []*variant{{env: map[string]string{}}}
+	for _, axis := range axes {
+		combined := []*variant{}
+		for _, partial := range variants {
+			for _, value := range axis.values {
+				env := map[string]string{axis.key: value}
+				for key, partialValue := range partial.env {
+					env[key] = partialValue
+				}
+
+				name := value
+				if partial.name != "" {
+					name = partial.name + "-" + value
+				}
+				combined = append(combined, &variant{name: invalidNameCharacters.ReplaceAllString(name, "_"), env: env})
+			}
+		}
+		variants = combined
+	}
+
+	return variants
+}
+
+// Creates a generator evaluating every unit as `v`. It shares the options and the graph of `g`, but has its own
+// caches, since the same file can resolve differently in another variant
+func (g *generator) forVariant(v *variant) *generator {
+	env := map[string]string{}
+	for key, value := range g.env {
+		env[key] = value
+	}
+	for key, value := range v.env {
+		env[key] = value
+	}
+
+	return &generator{
+		opts:                      g.opts,
+		gitRoot:                   g.gitRoot,
+		units:                     newParsedUnitCache(),
+		dependenciesCache:         newGetDependenciesCache(),
+		cascadedDependenciesCache: newGetDependenciesCache(),
+		graph:                     g.graph,
+		reportedCycles:            g.reportedCycles,
+		rules:                     g.rules,
+		env:                       env,
+		stubbedFunctions:          g.stubbedFunctions,
+		cache:                     g.cache,
+		unresolvedFileReferences:  g.unresolvedFileReferences,
+		variant:                   v,
+		workspaceGenerators:       &workspaceGenerators{generators: map[string]*generator{}},
+	}
+}
+
+// The generators of the workspace variants of one generator, created the first time a unit uses them
+type workspaceGenerators struct {
+	mtx        sync.Mutex
+	generators map[string]*generator
+}
+
+// Returns the generator evaluating units in `workspace`, on top of the variant of `g`
+func (g *generator) forWorkspace(workspace string) *generator {
+	g.workspaceGenerators.mtx.Lock()
+	defer g.workspaceGenerators.mtx.Unlock()
+
+	if workspaceGenerator, ok := g.workspaceGenerators.generators[workspace]; ok {
+		return workspaceGenerator
+	}
+
+	v := &variant{
+		name:      invalidNameCharacters.ReplaceAllString(workspace, "_"),
+		env:       map[string]string{workspaceEnvVariable: workspace},
+		workspace: workspace,
+	}
+	if g.variant != nil {
+		v.name = g.variant.name + "-" + v.name
+	}
+
+	workspaceGenerator := g.forVariant(v)
+	g.workspaceGenerators.generators[workspace] = workspaceGenerator
+
+	return workspaceGenerator
+}
+
+// Gives a project of a variant a name and workspace distinct from those of the other variants of its dir
+func (g *generator) nameVariantProject(project *AtlantisProject, projectName string) {
+	if g.variant == nil {
+		return
+	}
+
+	project.Name = projectName + "-" + g.variant.name
+	project.Workspace = g.variant.workspace
+	if project.Workspace == "" {
+		project.Workspace = g.variant.name
+	}
+	project.variant = g.variant.name
+}
+
+// Picks the projects of a dir a project of `variantName` depends on: the ones of the same variant, or else all of them
+func sameVariantProjects(projects []*AtlantisProject, variantName string) []*AtlantisProject {
+	sameVariant := []*AtlantisProject{}
+	for _, project := range projects {
+		if project.variant == variantName {
+			sameVariant = append(sameVariant, project)
+		}
+	}
+	if len(sameVariant) == 0 {
+		return projects
+	}
+
+	return sameVariant
+}
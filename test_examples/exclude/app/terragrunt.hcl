@@ -0,0 +1,21 @@
+terraform {
+  source = "git::https://example.com/modules.git//app"
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+dependency "legacy" {
+  config_path = "../legacy"
+  enabled     = false
+}
+
+dependency "reports" {
+  config_path = "../output_only"
+  enabled     = get_env("DEPLOY_ENV", "dev") == "prod"
+}
+
+inputs = {
+  vpc_id = dependency.vpc.outputs.vpc_id
+}
@@ -0,0 +1,8 @@
+terraform {
+  source = "git::https://example.com/modules.git//legacy"
+}
+
+exclude {
+  if      = true
+  actions = ["plan", "apply"]
+}
@@ -0,0 +1,8 @@
+terraform {
+  source = "git::https://example.com/modules.git//reports"
+}
+
+exclude {
+  if      = true
+  actions = ["output"]
+}
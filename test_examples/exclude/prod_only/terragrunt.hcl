@@ -0,0 +1,8 @@
+terraform {
+  source = "git::https://example.com/modules.git//backups"
+}
+
+exclude {
+  if      = get_env("DEPLOY_ENV", "dev") != "prod"
+  actions = ["all"]
+}
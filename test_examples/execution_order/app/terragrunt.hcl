@@ -0,0 +1,20 @@
+terraform {
+  source = "git::https://example.com/modules.git//app"
+}
+
+# Reading a file of another module doesn't make it a dependency, so app isn't ordered after shared
+locals {
+  settings = read_terragrunt_config("../shared/settings.hcl")
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+dependency "db" {
+  config_path = "../db"
+}
+
+inputs = {
+  region = local.settings.locals.region
+}
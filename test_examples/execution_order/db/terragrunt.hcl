@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//db"
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
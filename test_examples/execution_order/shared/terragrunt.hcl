@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//shared"
+}
+
+locals {
+  settings = read_terragrunt_config("settings.hcl")
+}
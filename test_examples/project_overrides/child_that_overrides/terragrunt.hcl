@@ -0,0 +1,21 @@
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_project_overrides = {
+    autoplan = {
+      when_modified = ["*.hcl"]
+    }
+    plan_requirements = ["approved"]
+    policy_check      = null
+  }
+}
+
+inputs = {
+  foo = "bar"
+}
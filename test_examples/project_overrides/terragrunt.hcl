@@ -0,0 +1,8 @@
+locals {
+  atlantis_project_overrides = {
+    autoplan = {
+      enabled = false
+    }
+    policy_check = true
+  }
+}
@@ -0,0 +1,21 @@
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_plan_requirements             = []
+  atlantis_branch                        = "/release-.*/"
+  atlantis_repo_locks                    = { mode = "disabled" }
+  atlantis_custom_policy_check           = false
+  atlantis_silence_pr_comments           = ["plan", "apply"]
+  atlantis_terraform_distribution        = "terraform"
+  atlantis_delete_source_branch_on_merge = false
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,10 @@
+locals {
+  atlantis_plan_requirements             = ["approved"]
+  atlantis_import_requirements           = ["approved", "mergeable"]
+  atlantis_branch                        = "/main/"
+  atlantis_repo_locks                    = { mode = "on_apply" }
+  atlantis_custom_policy_check           = true
+  atlantis_silence_pr_comments           = ["apply"]
+  atlantis_terraform_distribution        = "opentofu"
+  atlantis_delete_source_branch_on_merge = true
+}
@@ -0,0 +1,16 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  env_vars  = read_terragrunt_config(find_in_parent_folders("common.hcl"))
+  tfvars    = jsondecode(read_tfvars_file("${get_terragrunt_dir()}/../common.tfvars"))
+  policy    = file("policy.json")
+  user_data = templatefile("${get_terragrunt_dir()}/templates/user_data.tpl", { env = local.env_vars.locals.env })
+}
+
+inputs = {
+  region    = local.tfvars.region
+  policy    = local.policy
+  user_data = local.user_data
+}
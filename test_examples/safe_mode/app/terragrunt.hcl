@@ -0,0 +1,10 @@
+locals {
+  workflow = run_cmd("--terragrunt-quiet", "echo", "from-run-cmd")
+
+  atlantis_workflow          = local.workflow
+  atlantis_terraform_version = "1.9.0"
+}
+
+terraform {
+  source = "../module"
+}
@@ -0,0 +1,18 @@
+unit "api" {
+  source = find_in_parent_folders("catalog/units/app")
+  path   = "api"
+  values = {
+    env = values.env
+  }
+}
+
+unit "worker" {
+  source = find_in_parent_folders("catalog/units/app")
+  path   = "worker"
+
+  no_dot_terragrunt_stack = true
+
+  values = {
+    env = values.env
+  }
+}
@@ -0,0 +1,29 @@
+locals {
+  common = read_terragrunt_config(find_in_parent_folders("common.hcl"))
+  env    = "dev"
+}
+
+unit "vpc" {
+  source = "../../catalog/units/vpc"
+  path   = "vpc"
+  values = {
+    cidr   = "10.0.0.0/16"
+    region = local.common.locals.region
+  }
+}
+
+unit "app" {
+  source = "../../catalog/units/app"
+  path   = "app"
+  values = {
+    env = local.env
+  }
+}
+
+stack "services" {
+  source = "../../catalog/stacks/services"
+  path   = "services"
+  values = {
+    env = local.env
+  }
+}
@@ -0,0 +1,8 @@
+terraform {
+  source = "git::https://example.com/modules.git//vpc"
+}
+
+inputs = {
+  cidr   = values.cidr
+  region = values.region
+}
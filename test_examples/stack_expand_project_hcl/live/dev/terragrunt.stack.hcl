@@ -0,0 +1,8 @@
+unit "vpc" {
+  source = "../../catalog/units/vpc"
+  path   = "vpc"
+  values = {
+    cidr   = "10.0.0.0/16"
+    region = "us-east-1"
+  }
+}
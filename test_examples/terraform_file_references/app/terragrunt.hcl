@@ -0,0 +1,7 @@
+terraform {
+  source = "../modules/iam"
+}
+
+inputs = {
+  role = "deployer"
+}
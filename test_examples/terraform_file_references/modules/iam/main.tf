@@ -0,0 +1,21 @@
+variable "role" {}
+
+variable "policy_path" {
+  default = ""
+}
+
+resource "aws_iam_policy" "policy" {
+  policy = file("${path.module}/policies/policy.json")
+}
+
+resource "aws_launch_template" "template" {
+  user_data = base64encode(templatefile("${path.module}/templates/user_data.tpl", { role = var.role }))
+}
+
+resource "aws_iam_role" "role" {
+  assume_role_policy = file("${path.module}/roles/${var.role}.json")
+}
+
+resource "aws_iam_policy" "extra" {
+  policy = file(var.policy_path)
+}
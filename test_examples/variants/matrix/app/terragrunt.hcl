@@ -0,0 +1,13 @@
+locals {
+  environment = get_env("TG_ENV", "dev")
+
+  atlantis_workflow = "deploy-${local.environment}"
+}
+
+terraform {
+  source = "../module"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
@@ -0,0 +1,17 @@
+locals {
+  environment = get_env("TG_ENV", "dev")
+
+  atlantis_workflow = "deploy-${local.environment}"
+}
+
+terraform {
+  source = "../module"
+
+  extra_arguments "environment_vars" {
+    commands = get_terraform_commands_that_need_vars()
+
+    optional_var_files = [
+      "${get_terragrunt_dir()}/${local.environment}.tfvars",
+    ]
+  }
+}
@@ -0,0 +1,15 @@
+locals {
+  atlantis_workspaces = ["blue", "green"]
+}
+
+terraform {
+  source = "../module"
+
+  extra_arguments "workspace_vars" {
+    commands = get_terraform_commands_that_need_vars()
+
+    optional_var_files = [
+      "${get_terragrunt_dir()}/${get_env("TF_WORKSPACE", "default")}.tfvars",
+    ]
+  }
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//app"
+}
+
+dependency "database" {
+  config_path = "../database"
+}
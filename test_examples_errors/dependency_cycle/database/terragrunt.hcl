@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//database"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//network"
+}
+
+dependency "app" {
+  config_path = "../app"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://example.com/modules.git//reports"
+}
+
+dependency "app" {
+  config_path = "../app"
+}